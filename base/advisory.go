@@ -0,0 +1,72 @@
+package base
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AdvisoryLock represents an advisory lock held by a session
+// Held is the time in seconds since the lock has been seen for the first time
+type AdvisoryLock struct {
+	Pid      int64
+	Db       string
+	ClassID  int64
+	ObjID    int64
+	ObjSubID int64
+	Held     float64
+}
+
+// Key returns a unique identifier of the lock held by the session
+func (l *AdvisoryLock) Key() string {
+	return fmt.Sprintf("%d:%d:%d:%d", l.Pid, l.ClassID, l.ObjID, l.ObjSubID)
+}
+
+// String returns lock keys
+func (l *AdvisoryLock) String() string {
+	return fmt.Sprintf("%d:%d", l.ClassID, l.ObjID)
+}
+
+// Range represents an inclusive interval of integers
+type Range struct {
+	Min int64
+	Max int64
+}
+
+// ParseRange creates a Range from a string like "10-20" or "42"
+func ParseRange(s string) (r Range, err error) {
+	bounds := strings.SplitN(strings.TrimSpace(s), "-", 2)
+	r.Min, err = strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+	if err != nil {
+		return r, fmt.Errorf("invalid range '%s': %v", s, err)
+	}
+	r.Max = r.Min
+	if len(bounds) == 2 {
+		r.Max, err = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid range '%s': %v", s, err)
+		}
+	}
+	if r.Min > r.Max {
+		return r, fmt.Errorf("invalid range '%s': minimum is greater than maximum", s)
+	}
+	return r, nil
+}
+
+// Contains returns true when the value is included in the range
+func (r Range) Contains(value int64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+// InRanges returns true when the value is included in one of the ranges or when there's no range
+func InRanges(value int64, ranges []Range) bool {
+	if ranges == nil {
+		return true
+	}
+	for _, r := range ranges {
+		if r.Contains(value) {
+			return true
+		}
+	}
+	return false
+}
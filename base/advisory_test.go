@@ -0,0 +1,69 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Range
+		wantErr bool
+	}{
+		{"Single value", "42", Range{Min: 42, Max: 42}, false},
+		{"Interval", "10-20", Range{Min: 10, Max: 20}, false},
+		{"Interval with spaces", " 10 - 20 ", Range{Min: 10, Max: 20}, false},
+		{"Reversed interval", "20-10", Range{}, true},
+		{"Not a number", "test", Range{}, true},
+		{"Empty", "", Range{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseRange(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got no error; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInRanges(t *testing.T) {
+	ranges := []Range{{Min: 10, Max: 20}, {Min: 42, Max: 42}}
+
+	tests := []struct {
+		name   string
+		value  int64
+		ranges []Range
+		want   bool
+	}{
+		{"No range", 1, nil, true},
+		{"Lower bound", 10, ranges, true},
+		{"Upper bound", 20, ranges, true},
+		{"Single value", 42, ranges, true},
+		{"Outside ranges", 21, ranges, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := InRanges(tc.value, tc.ranges)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
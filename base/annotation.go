@@ -0,0 +1,54 @@
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// annotationRegex matches an annotation comment at the start of a query
+var annotationRegex = regexp.MustCompile(`^\s*/\*\s*pgterminate:\s*(.*?)\s*\*/`)
+
+// Annotation represents thresholds overridden by a comment at the start of a query like
+// "/* pgterminate: active-timeout=1h */" or "/* pgterminate: exempt */"
+// A zero timeout means the timeout is not overridden
+type Annotation struct {
+	Text          string
+	Exempt        bool
+	ActiveTimeout float64
+	IdleTimeout   float64
+}
+
+// ParseAnnotation returns the annotation of a query or nil when the query is not annotated
+// Multiple items can be separated by commas or spaces
+func ParseAnnotation(query string) (*Annotation, error) {
+	matches := annotationRegex.FindStringSubmatch(query)
+	if matches == nil {
+		return nil, nil
+	}
+	annotation := &Annotation{Text: matches[1]}
+	items := strings.FieldsFunc(matches[1], func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(items) == 0 {
+		return nil, fmt.Errorf("empty annotation")
+	}
+	for _, item := range items {
+		parts := strings.SplitN(item, "=", 2)
+		var err error
+		switch {
+		case parts[0] == "exempt" && len(parts) == 1:
+			annotation.Exempt = true
+		case parts[0] == "active-timeout" && len(parts) == 2:
+			annotation.ActiveTimeout, err = ParseSettingDuration(parts[1])
+		case parts[0] == "idle-timeout" && len(parts) == 2:
+			annotation.IdleTimeout, err = ParseSettingDuration(parts[1])
+		default:
+			return nil, fmt.Errorf("unknown annotation '%s'", item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation '%s': %v", item, err)
+		}
+	}
+	return annotation, nil
+}
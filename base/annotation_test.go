@@ -0,0 +1,49 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestParseAnnotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    *Annotation
+		wantErr bool
+	}{
+		{"No annotation", "select 1", nil, false},
+		{"Other comment", "/* report */ select 1", nil, false},
+		{"Annotation not at the start", "select 1 /* pgterminate: exempt */", nil, false},
+		{"Exempt", "/* pgterminate: exempt */ select 1", &Annotation{Text: "exempt", Exempt: true}, false},
+		{"Active timeout", "/*pgterminate: active-timeout=1h*/ select 1", &Annotation{Text: "active-timeout=1h", ActiveTimeout: 3600}, false},
+		{
+			"Multiple items",
+			" /* pgterminate: active-timeout=30min, idle-timeout=60 */ select 1",
+			&Annotation{Text: "active-timeout=30min, idle-timeout=60", ActiveTimeout: 1800, IdleTimeout: 60},
+			false,
+		},
+		{"Unknown item", "/* pgterminate: forever */ select 1", nil, true},
+		{"Invalid duration", "/* pgterminate: active-timeout=long */ select 1", nil, true},
+		{"Empty", "/* pgterminate: */ select 1", nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseAnnotation(tc.query)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got no error; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
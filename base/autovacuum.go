@@ -0,0 +1,17 @@
+package base
+
+import (
+	"time"
+)
+
+// AutovacuumWorker represents an autovacuum worker with its progress
+type AutovacuumWorker struct {
+	Session     *Session
+	Started     time.Time
+	Relid       int64
+	Relation    string
+	Phase       string
+	Wraparound  bool
+	Waiters     int
+	BlockingDDL bool
+}
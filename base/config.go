@@ -17,42 +17,122 @@ var AppName string
 
 // Config receives configuration options
 type Config struct {
-	mutex                         sync.Mutex
-	File                          string
-	Host                          string      `yaml:"host"`
-	Port                          int         `yaml:"port"`
-	User                          string      `yaml:"user"`
-	Password                      string      `yaml:"password"`
-	Database                      string      `yaml:"database"`
-	SSLMode                       string      `yaml:"sslmode"`
-	Interval                      float64     `yaml:"interval"`
-	ConnectTimeout                int         `yaml:"connect-timeout"`
-	IdleTimeout                   float64     `yaml:"idle-timeout"`
-	ActiveTimeout                 float64     `yaml:"active-timeout"`
-	LogDestination                string      `yaml:"log-destination"`
-	LogFile                       string      `yaml:"log-file"`
-	LogFormat                     string      `yaml:"log-format"`
-	PidFile                       string      `yaml:"pid-file"`
-	SyslogIdent                   string      `yaml:"syslog-ident"`
-	SyslogFacility                string      `yaml:"syslog-facility"`
-	IncludeUsers                  StringFlags `yaml:"include-users"`
-	IncludeUsersRegex             string      `yaml:"include-users-regex"`
-	IncludeUsersRegexCompiled     *regexp.Regexp
-	IncludeUsersFilters           []Filter
-	ExcludeUsers                  StringFlags `yaml:"exclude-users"`
-	ExcludeUsersRegex             string      `yaml:"exclude-users-regex"`
-	ExcludeUsersRegexCompiled     *regexp.Regexp
-	ExcludeUsersFilters           []Filter
-	IncludeDatabases              StringFlags `yaml:"include-databases"`
-	IncludeDatabasesRegex         string      `yaml:"include-databases-regex"`
-	IncludeDatabasesRegexCompiled *regexp.Regexp
-	IncludeDatabasesFilters       []Filter
-	ExcludeDatabases              StringFlags `yaml:"exclude-databases"`
-	ExcludeDatabasesRegex         string      `yaml:"exclude-databases-regex"`
-	ExcludeDatabasesRegexCompiled *regexp.Regexp
-	ExcludeDatabasesFilters       []Filter
-	ExcludeListeners              bool `yaml:"exclude-listeners"`
-	Cancel                        bool `yaml:"cancel"`
+	mutex                            sync.Mutex
+	policiesMutex                    sync.RWMutex
+	File                             string
+	Host                             string      `yaml:"host"`
+	Port                             int         `yaml:"port"`
+	User                             string      `yaml:"user"`
+	Password                         string      `yaml:"password"`
+	Database                         string      `yaml:"database"`
+	SSLMode                          string      `yaml:"sslmode"`
+	Interval                         float64     `yaml:"interval"`
+	ConnectTimeout                   int         `yaml:"connect-timeout"`
+	IdleTimeout                      float64     `yaml:"idle-timeout"`
+	ActiveTimeout                    float64     `yaml:"active-timeout"`
+	LogDestination                   string      `yaml:"log-destination"`
+	LogFile                          string      `yaml:"log-file"`
+	LogFormat                        string      `yaml:"log-format"`
+	PidFile                          string      `yaml:"pid-file"`
+	SyslogIdent                      string      `yaml:"syslog-ident"`
+	SyslogFacility                   string      `yaml:"syslog-facility"`
+	IncludeUsers                     StringFlags `yaml:"include-users"`
+	IncludeUsersRegex                string      `yaml:"include-users-regex"`
+	IncludeUsersRegexCompiled        *regexp.Regexp
+	IncludeUsersFilters              []Filter
+	ExcludeUsers                     StringFlags `yaml:"exclude-users"`
+	ExcludeUsersRegex                string      `yaml:"exclude-users-regex"`
+	ExcludeUsersRegexCompiled        *regexp.Regexp
+	ExcludeUsersFilters              []Filter
+	IncludeDatabases                 StringFlags `yaml:"include-databases"`
+	IncludeDatabasesRegex            string      `yaml:"include-databases-regex"`
+	IncludeDatabasesRegexCompiled    *regexp.Regexp
+	IncludeDatabasesFilters          []Filter
+	ExcludeDatabases                 StringFlags `yaml:"exclude-databases"`
+	ExcludeDatabasesRegex            string      `yaml:"exclude-databases-regex"`
+	ExcludeDatabasesRegexCompiled    *regexp.Regexp
+	ExcludeDatabasesFilters          []Filter
+	ExcludeSuperusers                bool               `yaml:"exclude-superusers"`
+	ExcludeReplicationRoles          bool               `yaml:"exclude-replication-roles"`
+	IncludeMembersOf                 StringFlags        `yaml:"include-members-of"`
+	RolesRefreshInterval             float64            `yaml:"roles-refresh-interval"`
+	DatabaseSettings                 bool               `yaml:"database-settings"`
+	SettingsRefreshInterval          float64            `yaml:"settings-refresh-interval"`
+	DisableAnnotations               bool               `yaml:"disable-annotations"`
+	AnnotationsDisabledUsers         StringFlags        `yaml:"annotations-disabled-users"`
+	AnnotationCeiling                float64            `yaml:"annotation-ceiling"`
+	AnnotationCeilings               map[string]float64 `yaml:"annotation-ceilings"`
+	Policies                         []*Policy          `yaml:"policies"`
+	PoliciesDatabase                 string             `yaml:"policies-database"`
+	PoliciesTable                    string             `yaml:"policies-table"`
+	PoliciesRefreshInterval          float64            `yaml:"policies-refresh-interval"`
+	DatabasePolicies                 []*Policy
+	MatchExpression                  string `yaml:"match"`
+	MatchCompiled                    Expression
+	Hook                             string      `yaml:"hook"`
+	HookTimeout                      float64     `yaml:"hook-timeout"`
+	HookFailMode                     string      `yaml:"hook-fail-mode"`
+	SSLRequired                      bool        `yaml:"ssl-required"`
+	SSLMinProtocolVersion            string      `yaml:"ssl-min-protocol-version"`
+	SSLCiphers                       StringFlags `yaml:"ssl-ciphers"`
+	SSLClientDNRegex                 string      `yaml:"ssl-client-dn-regex"`
+	SSLClientDNRegexCompiled         *regexp.Regexp
+	GSSAPIEncryptionAllowed          bool    `yaml:"gssapi-encryption-allowed"`
+	SweepDisabledRoles               bool    `yaml:"sweep-disabled-roles"`
+	SweepDisallowedDatabases         bool    `yaml:"sweep-disallowed-databases"`
+	HbaCheck                         bool    `yaml:"hba-check"`
+	HbaCheckInterval                 float64 `yaml:"hba-check-interval"`
+	HbaTerminate                     bool    `yaml:"hba-terminate"`
+	IdleInWriteTransactionTimeout    float64 `yaml:"idle-in-write-transaction-timeout"`
+	IdleInReadOnlyTransactionTimeout float64 `yaml:"idle-in-read-only-transaction-timeout"`
+	LabelsSeparator                  string  `yaml:"labels-separator"`
+	LabelsAssignment                 string  `yaml:"labels-assignment"`
+	LabelsRegex                      string  `yaml:"labels-regex"`
+	LabelsParser                     LabelParser
+	RSSLimit                         int64       `yaml:"rss-limit"`
+	CPULimit                         float64     `yaml:"cpu-limit"`
+	ReadBytesLimit                   int64       `yaml:"read-bytes-limit"`
+	AdaptiveFactor                   float64     `yaml:"adaptive-factor"`
+	AdaptiveMinTimeout               float64     `yaml:"adaptive-min-timeout"`
+	AdaptiveMaxTimeout               float64     `yaml:"adaptive-max-timeout"`
+	AdaptiveMinSamples               int         `yaml:"adaptive-min-samples"`
+	AdaptiveStatsFile                string      `yaml:"adaptive-stats-file"`
+	AdaptiveSaveInterval             float64     `yaml:"adaptive-save-interval"`
+	AdaptiveMaxAge                   float64     `yaml:"adaptive-max-age"`
+	AdaptiveMaxFingerprints          int         `yaml:"adaptive-max-fingerprints"`
+	RepeatOffenderKills              int         `yaml:"repeat-offender-kills"`
+	RepeatOffenderWindow             float64     `yaml:"repeat-offender-window"`
+	RepeatOffenderCooldown           float64     `yaml:"repeat-offender-cooldown"`
+	RepeatOffenderConnectionLimit    int         `yaml:"repeat-offender-connection-limit"`
+	RepeatOffenderPolicy             string      `yaml:"repeat-offender-policy"`
+	RepeatOffenderStateFile          string      `yaml:"repeat-offender-state-file"`
+	ExcludeListeners                 bool        `yaml:"exclude-listeners"`
+	Cancel                           bool        `yaml:"cancel"`
+	ReplicationLagBytes              int64       `yaml:"replication-lag-bytes"`
+	ReplicationLagTime               float64     `yaml:"replication-lag-time"`
+	SlotRetainedBytes                int64       `yaml:"slot-retained-bytes"`
+	DropSlots                        bool        `yaml:"drop-slots"`
+	StandbyIdleTimeout               float64     `yaml:"standby-idle-timeout"`
+	StandbyActiveTimeout             float64     `yaml:"standby-active-timeout"`
+	StandbyReplayLag                 float64     `yaml:"standby-replay-lag"`
+	LockQueueSize                    int         `yaml:"lock-queue-size"`
+	LockQueueAction                  string      `yaml:"lock-queue-action"`
+	LockQueueIdleInTransaction       bool        `yaml:"lock-queue-idle-in-transaction"`
+	Relations                        StringFlags `yaml:"relations"`
+	RelationsRegex                   string      `yaml:"relations-regex"`
+	RelationsRegexCompiled           *regexp.Regexp
+	AdvisoryLockIdleTimeout          float64     `yaml:"advisory-lock-idle-timeout"`
+	AdvisoryLockTimeout              float64     `yaml:"advisory-lock-timeout"`
+	AdvisoryLockClassIDs             StringFlags `yaml:"advisory-lock-classids"`
+	AdvisoryLockClassIDsRanges       []Range
+	AdvisoryLockObjIDs               StringFlags `yaml:"advisory-lock-objids"`
+	AdvisoryLockObjIDsRanges         []Range
+	MaintenanceTimeout               float64 `yaml:"maintenance-timeout"`
+	BackupTimeout                    float64 `yaml:"backup-timeout"`
+	AutovacuumBlockingDDL            bool    `yaml:"autovacuum-blocking-ddl"`
+	AutovacuumLockQueueSize          int     `yaml:"autovacuum-lock-queue-size"`
+	AutovacuumWindow                 string  `yaml:"autovacuum-window"`
+	AutovacuumWindowCompiled         *Window
 }
 
 func init() {
@@ -89,16 +169,72 @@ func (c *Config) Reload() {
 	log.Debug("Reloading configuration")
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	// Policies are replaced by reading the file, while they are read by ActivePolicies
+	c.policiesMutex.Lock()
 	if c.File != "" {
 		c.Read(c.File)
 	}
-	err := c.CompileRegexes()
+	err := c.CompilePolicies()
+	c.policiesMutex.Unlock()
+	Panic(err)
+	err = c.CompileRegexes()
+	Panic(err)
+	err = c.CompileRanges()
 	Panic(err)
 	c.CompileFilters()
 }
 
+// HasRules returns true when at least one rule is configured
+func (c *Config) HasRules() bool {
+	return c.ActiveTimeout != 0 || c.IdleTimeout != 0 || c.DatabaseSettings ||
+		c.IdleInWriteTransactionTimeout != 0 || c.IdleInReadOnlyTransactionTimeout != 0 ||
+		c.Policies != nil || c.PoliciesDatabase != "" ||
+		c.ReplicationLagBytes != 0 || c.ReplicationLagTime != 0 || c.SlotRetainedBytes != 0 ||
+		c.StandbyActiveTimeout != 0 || c.StandbyIdleTimeout != 0 || c.StandbyReplayLag != 0 ||
+		c.LockQueueSize != 0 ||
+		c.Relations != nil || c.RelationsRegex != "" ||
+		c.AdvisoryLockIdleTimeout != 0 || c.AdvisoryLockTimeout != 0 ||
+		c.AutovacuumBlockingDDL || c.AutovacuumLockQueueSize != 0 || c.AutovacuumWindow != "" ||
+		c.SecurityRequirement().Enabled() || c.SweepDisabledRoles || c.SweepDisallowedDatabases ||
+		c.HbaCheck || c.HasResourceLimits() || c.Learning()
+}
+
+// Learning returns true when durations of queries are learned by fingerprint, to cancel
+// queries running longer than usual or to fill the statistics file only
+func (c *Config) Learning() bool {
+	return c.AdaptiveFactor != 0 || c.AdaptiveStatsFile != ""
+}
+
+// HasResourceLimits returns true when at least one limit on operating system resources is
+// configured
+func (c *Config) HasResourceLimits() bool {
+	return c.RSSLimit != 0 || c.CPULimit != 0 || c.ReadBytesLimit != 0
+}
+
+// SecurityRequirement returns the encryption sessions must use
+func (c *Config) SecurityRequirement() *SecurityRequirement {
+	return &SecurityRequirement{
+		SSLRequired:      c.SSLRequired,
+		MinProtocol:      c.SSLMinProtocolVersion,
+		Ciphers:          c.SSLCiphers,
+		ClientDNRegex:    c.SSLClientDNRegexCompiled,
+		GSSAPIEncryption: c.GSSAPIEncryptionAllowed,
+	}
+}
+
+// HasRoleFilters returns true when at least one filter on role attributes or memberships
+// is configured
+func (c *Config) HasRoleFilters() bool {
+	return c.ExcludeSuperusers || c.ExcludeReplicationRoles || c.IncludeMembersOf != nil
+}
+
 // Dsn formats a connection string based on Config
 func (c *Config) Dsn() string {
+	return c.DatabaseDsn(c.Database)
+}
+
+// DatabaseDsn formats a connection string based on Config to connect to another database
+func (c *Config) DatabaseDsn(database string) string {
 	var parameters []string
 	if c.Host != "" {
 		parameters = append(parameters, fmt.Sprintf("host=%s", c.Host))
@@ -112,8 +248,8 @@ func (c *Config) Dsn() string {
 	if c.Password != "" {
 		parameters = append(parameters, fmt.Sprintf("password=%s", c.Password))
 	}
-	if c.Database != "" {
-		parameters = append(parameters, fmt.Sprintf("database=%s", c.Database))
+	if database != "" {
+		parameters = append(parameters, fmt.Sprintf("database=%s", database))
 	}
 	if c.ConnectTimeout != 0 {
 		parameters = append(parameters, fmt.Sprintf("connect_timeout=%d", c.ConnectTimeout))
@@ -153,9 +289,103 @@ func (c *Config) CompileRegexes() (err error) {
 			return err
 		}
 	}
+	if c.RelationsRegex != "" {
+		c.RelationsRegexCompiled, err = regexp.Compile(c.RelationsRegex)
+		if err != nil {
+			return err
+		}
+	}
+	c.SSLClientDNRegexCompiled = nil
+	if c.SSLClientDNRegex != "" {
+		c.SSLClientDNRegexCompiled, err = regexp.Compile(c.SSLClientDNRegex)
+		if err != nil {
+			return err
+		}
+	}
+	c.LabelsParser, err = NewLabelParser(c.LabelsSeparator, c.LabelsAssignment, c.LabelsRegex)
+	if err != nil {
+		return err
+	}
+	c.MatchCompiled = nil
+	if c.MatchExpression != "" {
+		c.MatchCompiled, err = ParseExpression(c.MatchExpression)
+		if err != nil {
+			return fmt.Errorf("match: %v", err)
+		}
+	}
 	return nil
 }
 
+// CompileRanges transforms ranges from string to Range instances
+func (c *Config) CompileRanges() (err error) {
+	c.AdvisoryLockClassIDsRanges, err = parseRanges(c.AdvisoryLockClassIDs)
+	if err != nil {
+		return err
+	}
+	c.AdvisoryLockObjIDsRanges, err = parseRanges(c.AdvisoryLockObjIDs)
+	if err != nil {
+		return err
+	}
+	c.AutovacuumWindowCompiled = nil
+	if c.AutovacuumWindow != "" {
+		c.AutovacuumWindowCompiled, err = ParseWindow(c.AutovacuumWindow)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRanges transforms a list of strings into a list of Range
+func parseRanges(values []string) (ranges []Range, err error) {
+	for _, value := range values {
+		r, err := ParseRange(value)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// CompilePolicies validates policies from the configuration file and compiles their regexes
+func (c *Config) CompilePolicies() error {
+	return CompilePolicies(c.Policies)
+}
+
+// ReadPolicies loads policies from the policies table, validates them and replaces policies
+// previously loaded from the table
+// Previous policies are kept when the table can't be read or new policies are invalid
+func (c *Config) ReadPolicies() error {
+	db := NewDb(c.DatabaseDsn(c.PoliciesDatabase))
+	if err := db.Open(); err != nil {
+		return err
+	}
+	defer db.Disconnect()
+
+	policies, err := db.Policies(c.PoliciesTable)
+	if err != nil {
+		return err
+	}
+	if err = CompilePolicies(policies); err != nil {
+		return err
+	}
+
+	c.policiesMutex.Lock()
+	defer c.policiesMutex.Unlock()
+	c.DatabasePolicies = policies
+	return nil
+}
+
+// ActivePolicies returns policies from the policies table followed by policies from the
+// configuration file
+func (c *Config) ActivePolicies() (policies []*Policy) {
+	c.policiesMutex.RLock()
+	defer c.policiesMutex.RUnlock()
+	policies = append(policies, c.DatabasePolicies...)
+	return append(policies, c.Policies...)
+}
+
 // CompileFilters creates Filter objects based on patterns and compiled regexp
 func (c *Config) CompileFilters() {
 
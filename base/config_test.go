@@ -0,0 +1,39 @@
+package base
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConfigReloadPolicies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "policies:\n  - name: reports\n    users-regex: \"^report_\"\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := NewConfig()
+	config.File = file
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			config.Reload()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		for _, policy := range config.ActivePolicies() {
+			if policy.Name != "reports" {
+				t.Errorf("got policy %s; want reports", policy.Name)
+			}
+		}
+	}
+	wg.Wait()
+
+	if got := config.ActivePolicies(); len(got) != 1 || got[0].UsersRegexCompiled == nil {
+		t.Errorf("got %+v; want compiled reports policy", got)
+	}
+}
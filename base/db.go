@@ -1,8 +1,12 @@
 package base
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jouir/pgterminate/log"
 	"github.com/lib/pq"
@@ -10,30 +14,87 @@ import (
 
 const (
 	maxQueryLength = 1000
+	// ddlModes are lock modes taken by DDL on relations
+	ddlModes = `('ShareLock', 'ShareRowExclusiveLock', 'ExclusiveLock', 'AccessExclusiveLock')`
 )
 
+// progressViews lists operations reported by pg_stat_progress views
+var progressViews = []string{"create_index", "vacuum", "cluster", "analyze", "copy", "basebackup"}
+
+// backendType returns the expression of the backend type of pg_stat_activity rows with the
+// given prefix, like "a."
+// The backend_type column appeared in PostgreSQL 10. Before, rows are client backends,
+// walsenders and autovacuum workers only.
+func backendType(version int, prefix string) string {
+	if version >= 100000 {
+		return "coalesce(" + prefix + "backend_type, '')"
+	}
+	return fmt.Sprintf(`case when %[1]squery like 'autovacuum:%%' then 'autovacuum worker'
+	            when %[1]spid in (select pid from pg_catalog.pg_stat_replication) then 'walsender'
+	            else 'client backend' end`, prefix)
+}
+
+// walFunction returns the name of a function about WAL positions, renamed from "xlog" and
+// "location" to "wal" and "lsn" in PostgreSQL 10
+func walFunction(version int, name string) string {
+	if version >= 100000 {
+		return name
+	}
+	return strings.NewReplacer("wal_lsn", "xlog_location", "wal", "xlog", "lsn", "location").Replace(name)
+}
+
+// currentLsn returns the expression of the last known WAL position on primaries and
+// standbys
+func currentLsn(version int) string {
+	return fmt.Sprintf(`case when pg_is_in_recovery() then %s() else %s() end`,
+		walFunction(version, "pg_last_wal_receive_lsn"), walFunction(version, "pg_current_wal_lsn"))
+}
+
 // Db centralizes connection to the database
 type Db struct {
-	dsn  string
-	conn *sql.DB
+	driver        string
+	dsn           string
+	conn          *sql.DB
+	progressViews []string
+	version       int
 }
 
 // NewDb creates a Db object
 func NewDb(dsn string) *Db {
+	return NewDriverDb("postgres", dsn)
+}
+
+// NewDriverDb creates a Db object using another database/sql driver than lib/pq, like a fake
+// driver in tests
+func NewDriverDb(driver string, dsn string) *Db {
 	return &Db{
-		dsn: dsn,
+		driver: driver,
+		dsn:    dsn,
 	}
 }
 
 // Connect connects to the instance and ping it to ensure connection is working
 func (db *Db) Connect() {
-	conn, err := sql.Open("postgres", db.dsn)
+	err := db.Open()
 	Panic(err)
+}
+
+// Open connects to the instance and ping it to ensure connection is working
+// Errors are returned instead of terminating the program
+func (db *Db) Open() error {
+	conn, err := sql.Open(db.driver, db.dsn)
+	if err != nil {
+		return err
+	}
 
 	err = conn.Ping()
-	Panic(err)
+	if err != nil {
+		conn.Close()
+		return err
+	}
 
 	db.conn = conn
+	return nil
 }
 
 // Disconnect ends connection cleanly
@@ -43,34 +104,167 @@ func (db *Db) Disconnect() {
 }
 
 // Sessions connects to the database and returns current sessions
-func (db *Db) Sessions() (sessions []*Session) {
+func (db *Db) Sessions() []*Session {
+	sessions, err := db.QuerySessions()
+	Panic(err)
+	return sessions
+}
+
+// QuerySessions returns current sessions or an error
+func (db *Db) QuerySessions() (sessions []*Session, err error) {
+	version, err := db.QueryVersion()
+	if err != nil {
+		return nil, err
+	}
+	queryID := "0"
+	if version >= 140000 {
+		queryID = "coalesce(query_id, 0)"
+	}
 	query := fmt.Sprintf(`select pid as pid,
 	      usename as user,
 	      datname as db,
 	      coalesce(host(client_addr)::text || ':' || client_port::text, 'localhost') as client,
 	      state as state, substring(query from 1 for %d) as query,
 		  coalesce(extract(epoch from now() - state_change), 0) as "stateDuration",
-		  application_name as "applicationName"
+		  application_name as "applicationName",
+		  %s as "backendType",
+		  case when backend_xid is not null then '%s'
+		       when xact_start is not null then '%s'
+		       else '' end as transaction,
+		  %s as "queryId",
+		  backend_start as "backendStart"
 	 from pg_catalog.pg_stat_activity
-	where pid <> pg_backend_pid();`, maxQueryLength)
+	where pid <> pg_backend_pid();`, maxQueryLength, backendType(version, ""), WriteTransaction, ReadOnlyTransaction, queryID)
 	log.Debugf("query: %s\n", query)
 	rows, err := db.conn.Query(query)
-	Panic(err)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var pid sql.NullInt64
 		var user, db, client, state, query, applicationName sql.NullString
 		var stateDuration float64
-		err := rows.Scan(&pid, &user, &db, &client, &state, &query, &stateDuration, &applicationName)
-		Panic(err)
+		var backendType, transaction string
+		var queryID int64
+		var backendStart sql.NullTime
+		if err = rows.Scan(&pid, &user, &db, &client, &state, &query, &stateDuration, &applicationName, &backendType, &transaction, &queryID, &backendStart); err != nil {
+			return nil, err
+		}
 
 		if pid.Valid && user.Valid && db.Valid && client.Valid && state.Valid && query.Valid && applicationName.Valid {
-			sessions = append(sessions, NewSession(pid.Int64, user.String, db.String, client.String, state.String, query.String, stateDuration, applicationName.String))
+			session := NewSession(pid.Int64, user.String, db.String, client.String, state.String, query.String, stateDuration, applicationName.String)
+			session.BackendType = backendType
+			session.Transaction = transaction
+			session.QueryID = queryID
+			session.BackendStart = backendStart.Time
+			sessions = append(sessions, session)
 		}
 	}
 
-	return sessions
+	return sessions, rows.Err()
+}
+
+// Version returns the version of the instance as a number like 140005
+// The version is looked up once
+func (db *Db) Version() int {
+	version, err := db.QueryVersion()
+	Panic(err)
+	return version
+}
+
+// QueryVersion returns the version of the instance or an error
+func (db *Db) QueryVersion() (int, error) {
+	if db.version == 0 {
+		query := `select current_setting('server_version_num')::int;`
+		log.Debugf("query: %s\n", query)
+		if err := db.conn.QueryRow(query).Scan(&db.version); err != nil {
+			return 0, err
+		}
+	}
+	return db.version, nil
+}
+
+// BackendPid returns the process id of the backend serving the connection
+func (db *Db) BackendPid() (pid int64) {
+	query := `select pg_backend_pid();`
+	log.Debugf("query: %s\n", query)
+	err := db.conn.QueryRow(query).Scan(&pid)
+	Panic(err)
+	return pid
+}
+
+// Progress returns maintenance operations reported by pg_stat_progress views by process id
+// Views are looked up once because they depend on the PostgreSQL version
+func (db *Db) Progress() map[int64]string {
+	operations, err := db.QueryProgress()
+	Panic(err)
+	return operations
+}
+
+// QueryProgress returns maintenance operations by process id or an error
+func (db *Db) QueryProgress() (operations map[int64]string, err error) {
+	if db.progressViews == nil {
+		if db.progressViews, err = db.views(progressViews); err != nil {
+			return nil, err
+		}
+	}
+	operations = make(map[int64]string)
+	if len(db.progressViews) == 0 {
+		return operations, nil
+	}
+
+	var selects []string
+	for _, view := range db.progressViews {
+		selects = append(selects, fmt.Sprintf("select pid, '%s' as operation from pg_catalog.pg_stat_progress_%s", view, view))
+	}
+	query := strings.Join(selects, " union all ") + ";"
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int64
+		var operation string
+		if err = rows.Scan(&pid, &operation); err != nil {
+			return nil, err
+		}
+		operations[pid] = operation
+	}
+
+	return operations, rows.Err()
+}
+
+// views returns progress views available on the instance among a list of operations
+func (db *Db) views(operations []string) ([]string, error) {
+	query := `select substring(viewname from 'pg_stat_progress_(.*)') as operation
+	 from pg_catalog.pg_views
+	where schemaname = 'pg_catalog'
+	  and viewname = any($1);`
+	var names []string
+	for _, operation := range operations {
+		names = append(names, "pg_stat_progress_"+operation)
+	}
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	available := []string{}
+	for rows.Next() {
+		var operation string
+		if err = rows.Scan(&operation); err != nil {
+			return nil, err
+		}
+		available = append(available, operation)
+	}
+	return available, rows.Err()
 }
 
 // TerminateSessions terminates a list of sessions
@@ -87,6 +281,51 @@ func (db *Db) TerminateSessions(sessions []*Session) {
 	}
 }
 
+// SignalSessions cancels current query of a list of sessions or terminates them, and returns
+// process ids of sessions signaled successfully
+// Sessions with a start time are only signaled when their backend started at this time, so a
+// process id reused by a new backend in the meantime is not signaled.
+func (db *Db) SignalSessions(sessions []*Session, cancel bool) (signaled map[int64]bool, err error) {
+	signaled = make(map[int64]bool)
+	var pids []int64
+	var starts []string
+	for _, session := range sessions {
+		pids = append(pids, session.Pid)
+		start := ""
+		if !session.BackendStart.IsZero() {
+			start = session.BackendStart.Format(time.RFC3339Nano)
+		}
+		starts = append(starts, start)
+	}
+	if len(pids) == 0 {
+		return signaled, nil
+	}
+	function := "pg_terminate_backend"
+	if cancel {
+		function = "pg_cancel_backend"
+	}
+	query := fmt.Sprintf(`select a.pid, %s(a.pid)
+	 from pg_catalog.pg_stat_activity a
+	 join unnest($1::int[], $2::text[]) as c(pid, backend_start) on c.pid = a.pid
+	where c.backend_start = '' or a.backend_start = nullif(c.backend_start, '')::timestamptz;`, function)
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query, pq.Array(pids), pq.Array(starts))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int64
+		var ok bool
+		if err = rows.Scan(&pid, &ok); err != nil {
+			return nil, err
+		}
+		signaled[pid] = ok
+	}
+	return signaled, rows.Err()
+}
+
 // CancelSessions terminates current query of a list of sessions
 func (db *Db) CancelSessions(sessions []*Session) {
 	var pids []int64
@@ -100,3 +339,645 @@ func (db *Db) CancelSessions(sessions []*Session) {
 		Panic(err)
 	}
 }
+
+// InRecovery returns true when the instance is a standby
+func (db *Db) InRecovery() (inRecovery bool) {
+	query := `select pg_is_in_recovery();`
+	log.Debugf("query: %s\n", query)
+	err := db.conn.QueryRow(query).Scan(&inRecovery)
+	Panic(err)
+	return inRecovery
+}
+
+// ReplayLag returns the time in seconds since the last replayed transaction on a standby
+// Lag is zero when all received WAL has been replayed, or when WAL is not streamed like on
+// standbys restoring WAL from archives only, because the last replayed transaction gets older
+// while nothing is left to replay
+func (db *Db) ReplayLag() (lag float64) {
+	query := replayLagQuery(db.Version())
+	log.Debugf("query: %s\n", query)
+	err := db.conn.QueryRow(query).Scan(&lag)
+	Panic(err)
+	return lag
+}
+
+// replayLagQuery returns the query computing replay lag for a version of the instance
+func replayLagQuery(version int) string {
+	receive, replay := walFunction(version, "pg_last_wal_receive_lsn"), walFunction(version, "pg_last_wal_replay_lsn")
+	return fmt.Sprintf(`select case when %[1]s() is null or %[1]s() = %[2]s() then 0
+	      else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)
+	      end as lag;`, receive, replay)
+}
+
+// Walsenders returns replication sessions with their lag
+func (db *Db) Walsenders() (walsenders []*Walsender) {
+	// Replay lag time is available since PostgreSQL 10
+	version := db.Version()
+	replayLsn, lagTime := "r.replay_lsn", "coalesce(extract(epoch from r.replay_lag), 0)"
+	if version < 100000 {
+		replayLsn, lagTime = "r.replay_location", "0"
+	}
+	query := fmt.Sprintf(`select r.pid as pid,
+	      r.usename as user,
+	      coalesce(a.datname, '') as db,
+	      coalesce(host(r.client_addr)::text || ':' || r.client_port::text, 'localhost') as client,
+	      r.state as state,
+	      coalesce(extract(epoch from now() - r.backend_start), 0) as "stateDuration",
+	      r.application_name as "applicationName",
+	      coalesce(%s(%s, %s), 0)::bigint as "lagBytes",
+	      %s as "lagTime"
+	 from pg_catalog.pg_stat_replication r
+	 left join pg_catalog.pg_stat_activity a on a.pid = r.pid;`, walFunction(version, "pg_wal_lsn_diff"), currentLsn(version), replayLsn, lagTime)
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid sql.NullInt64
+		var user, db, client, state, applicationName sql.NullString
+		var stateDuration, lagTime float64
+		var lagBytes int64
+		err := rows.Scan(&pid, &user, &db, &client, &state, &stateDuration, &applicationName, &lagBytes, &lagTime)
+		Panic(err)
+
+		if pid.Valid && user.Valid && state.Valid {
+			session := NewSession(pid.Int64, user.String, db.String, client.String, state.String, "", stateDuration, applicationName.String)
+			session.BackendType = "walsender"
+			walsenders = append(walsenders, &Walsender{
+				Session:  session,
+				LagBytes: lagBytes,
+				LagTime:  lagTime,
+			})
+		}
+	}
+
+	return walsenders
+}
+
+// Encryptions returns the encryption of client and replication sessions
+func (db *Db) Encryptions() (encryptions []*Encryption) {
+	// GSSAPI encryption is available since PostgreSQL 12
+	version := db.Version()
+	gssEncrypted, gssJoin := "false", ""
+	if version >= 120000 {
+		gssEncrypted = "coalesce(g.encrypted, false)"
+		gssJoin = "left join pg_catalog.pg_stat_gssapi g on g.pid = a.pid"
+	}
+	query := fmt.Sprintf(`select a.pid as pid,
+	      a.usename as user,
+	      coalesce(a.datname, '') as db,
+	      coalesce(host(a.client_addr)::text || ':' || a.client_port::text, 'localhost') as client,
+	      coalesce(a.state, '') as state,
+	      coalesce(substring(a.query from 1 for %d), '') as query,
+	      coalesce(extract(epoch from now() - a.state_change), 0) as "stateDuration",
+	      coalesce(a.application_name, '') as "applicationName",
+	      %s as "backendType",
+	      a.client_addr is null as local,
+	      coalesce(s.ssl, false) as ssl,
+	      coalesce(s.version, '') as version,
+	      coalesce(s.cipher, '') as cipher,
+	      coalesce(s.client_dn, '') as "clientDn",
+	      %s as "gssEncrypted"
+	 from pg_catalog.pg_stat_activity a
+	 left join pg_catalog.pg_stat_ssl s on s.pid = a.pid
+	 %s
+	where %s in ('client backend', 'walsender')
+	  and a.usename is not null
+	  and a.pid <> pg_backend_pid();`, maxQueryLength, backendType(version, "a."), gssEncrypted, gssJoin, backendType(version, "a."))
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int64
+		var user, db, client, state, query, applicationName, backendType string
+		var stateDuration float64
+		encryption := &Encryption{}
+		err := rows.Scan(&pid, &user, &db, &client, &state, &query, &stateDuration, &applicationName, &backendType,
+			&encryption.Local, &encryption.SSL, &encryption.Version, &encryption.Cipher, &encryption.ClientDN, &encryption.GSSEncrypted)
+		Panic(err)
+		encryption.Session = NewSession(pid, user, db, client, state, query, stateDuration, applicationName)
+		encryption.Session.BackendType = backendType
+		encryptions = append(encryptions, encryption)
+	}
+
+	return encryptions
+}
+
+// Disallowed returns sessions of roles with the NOLOGIN attribute or an expired password and
+// sessions connected to databases not allowing connections
+func (db *Db) Disallowed() (disallowed []*Disallowed) {
+	query := fmt.Sprintf(`select a.pid as pid,
+	      a.usename as user,
+	      coalesce(a.datname, '') as db,
+	      coalesce(host(a.client_addr)::text || ':' || a.client_port::text, 'localhost') as client,
+	      coalesce(a.state, '') as state,
+	      coalesce(substring(a.query from 1 for %d), '') as query,
+	      coalesce(extract(epoch from now() - a.state_change), 0) as "stateDuration",
+	      coalesce(a.application_name, '') as "applicationName",
+	      %[2]s as "backendType",
+	      r.rolcanlogin as "canLogin",
+	      coalesce(r.rolvaliduntil < now(), false) as expired,
+	      coalesce(r.rolvaliduntil::text, '') as "validUntil",
+	      coalesce(d.datallowconn, true) as "allowConnections"
+	 from pg_catalog.pg_stat_activity a
+	 join pg_catalog.pg_roles r on r.oid = a.usesysid
+	 left join pg_catalog.pg_database d on d.oid = a.datid
+	where %[2]s in ('client backend', 'walsender')
+	  and a.pid <> pg_backend_pid()
+	  and (not r.rolcanlogin or r.rolvaliduntil < now() or not d.datallowconn);`, maxQueryLength, backendType(db.Version(), "a."))
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int64
+		var user, db, client, state, query, applicationName, backendType string
+		var stateDuration float64
+		d := &Disallowed{}
+		err := rows.Scan(&pid, &user, &db, &client, &state, &query, &stateDuration, &applicationName, &backendType,
+			&d.CanLogin, &d.Expired, &d.ValidUntil, &d.AllowConnections)
+		Panic(err)
+		d.Session = NewSession(pid, user, db, client, state, query, stateDuration, applicationName)
+		d.Session.BackendType = backendType
+		disallowed = append(disallowed, d)
+	}
+
+	return disallowed
+}
+
+// Slots returns replication slots with the amount of WAL they retain
+func (db *Db) Slots() (slots []*Slot) {
+	query := fmt.Sprintf(`select slot_name as name,
+	      slot_type as type,
+	      coalesce(plugin, '') as plugin,
+	      coalesce(database, '') as db,
+	      active as active,
+	      coalesce(active_pid, 0) as "activePid",
+	      coalesce(%s(%s, restart_lsn), 0)::bigint as "retainedBytes"
+	 from pg_catalog.pg_replication_slots;`, walFunction(db.Version(), "pg_wal_lsn_diff"), currentLsn(db.Version()))
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		slot := &Slot{}
+		err := rows.Scan(&slot.Name, &slot.Type, &slot.Plugin, &slot.Db, &slot.Active, &slot.ActivePid, &slot.RetainedBytes)
+		Panic(err)
+		slots = append(slots, slot)
+	}
+
+	return slots
+}
+
+// DropSlots drops a list of inactive replication slots
+func (db *Db) DropSlots(slots []*Slot) {
+	query := `select pg_drop_replication_slot(slot_name) from pg_replication_slots where slot_name = $1 and not active;`
+	for _, slot := range slots {
+		log.Debugf("query: %s\n", query)
+		_, err := db.conn.Exec(query, slot.Name)
+		Panic(err)
+	}
+}
+
+// LockQueues returns DDL waiting for a relation lock with the number of sessions waiting behind them
+func (db *Db) LockQueues() (queues []*LockQueue) {
+	query := `with waiting as (
+	      select p.pid, pg_blocking_pids(p.pid) as blockers
+	        from (select distinct pid from pg_catalog.pg_locks where not granted and pid is not null) p
+	 )
+	 select l.pid as pid,
+	        case when l.database = d.oid then l.relation::regclass::text else l.relation::text end as relation,
+	        l.mode as mode,
+	        w.blockers as blockers,
+	        (select count(*) from waiting o where l.pid = any(o.blockers)) as waiters
+	   from pg_catalog.pg_locks l
+	   join waiting w on w.pid = l.pid
+	   left join pg_catalog.pg_database d on d.datname = current_database()
+	  where l.locktype = 'relation'
+	    and not l.granted
+	    and l.mode in ` + ddlModes + `;`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		queue := &LockQueue{}
+		var blockers pq.Int64Array
+		err := rows.Scan(&queue.Pid, &queue.Relation, &queue.Mode, &blockers, &queue.Waiters)
+		Panic(err)
+		queue.Blockers = blockers
+		queues = append(queues, queue)
+	}
+
+	return queues
+}
+
+// LockedDatabases returns databases with locks on relations
+func (db *Db) LockedDatabases() (databases []string) {
+	query := `select distinct d.datname as db
+	 from pg_catalog.pg_locks l
+	 join pg_catalog.pg_database d on d.oid = l.database
+	where l.locktype = 'relation';`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var database string
+		err := rows.Scan(&database)
+		Panic(err)
+		databases = append(databases, database)
+	}
+
+	return databases
+}
+
+// RelationLocks returns locks held or awaited on relations of the current database
+func (db *Db) RelationLocks() (locks []*RelationLock, err error) {
+	query := `select l.pid as pid,
+	      current_database() as db,
+	      n.nspname as schema,
+	      c.relname as relation,
+	      l.mode as mode,
+	      l.granted as granted
+	 from pg_catalog.pg_locks l
+	 join pg_catalog.pg_database d on d.oid = l.database and d.datname = current_database()
+	 join pg_catalog.pg_class c on c.oid = l.relation
+	 join pg_catalog.pg_namespace n on n.oid = c.relnamespace
+	where l.locktype = 'relation'
+	  and l.pid <> pg_backend_pid();`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lock := &RelationLock{}
+		if err = rows.Scan(&lock.Pid, &lock.Db, &lock.Schema, &lock.Relation, &lock.Mode, &lock.Granted); err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+
+	return locks, rows.Err()
+}
+
+// AdvisoryLocks returns advisory locks held by sessions
+func (db *Db) AdvisoryLocks() (locks []*AdvisoryLock) {
+	query := `select l.pid as pid,
+	      coalesce(d.datname, '') as db,
+	      l.classid::bigint as classid,
+	      l.objid::bigint as objid,
+	      l.objsubid::bigint as objsubid
+	 from pg_catalog.pg_locks l
+	 left join pg_catalog.pg_database d on d.oid = l.database
+	where l.locktype = 'advisory'
+	  and l.granted
+	  and l.pid <> pg_backend_pid();`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		lock := &AdvisoryLock{}
+		err := rows.Scan(&lock.Pid, &lock.Db, &lock.ClassID, &lock.ObjID, &lock.ObjSubID)
+		Panic(err)
+		locks = append(locks, lock)
+	}
+
+	return locks
+}
+
+// AutovacuumWorkers returns autovacuum workers with the relation they are processing
+// Relations of other databases are returned as oid
+func (db *Db) AutovacuumWorkers() (workers []*AutovacuumWorker) {
+	query := `select a.pid as pid,
+	      coalesce(a.datname, '') as db,
+	      coalesce(a.query, '') as query,
+	      coalesce(extract(epoch from now() - coalesce(a.xact_start, a.backend_start)), 0) as "stateDuration",
+	      coalesce(a.xact_start, a.backend_start) as started,
+	      coalesce(p.relid::bigint, 0) as relid,
+	      coalesce(case when a.datname = current_database() then p.relid::regclass::text else p.relid::text end, '') as relation,
+	      coalesce(p.phase, '') as phase,
+	      coalesce(a.query like '%to prevent wraparound%', false) as wraparound,
+	      (select count(*) from pg_catalog.pg_stat_activity w where a.pid = any(pg_blocking_pids(w.pid))) as waiters,
+	      exists (select 1 from pg_catalog.pg_locks l
+	               where l.locktype = 'relation'
+	                 and not l.granted
+	                 and l.mode in ` + ddlModes + `
+	                 and a.pid = any(pg_blocking_pids(l.pid))) as "blockingDdl"
+	 from pg_catalog.pg_stat_activity a
+	 left join pg_catalog.pg_stat_progress_vacuum p on p.pid = a.pid
+	where ` + backendType(db.Version(), "a.") + ` = 'autovacuum worker';`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int64
+		var database, text string
+		var stateDuration float64
+		worker := &AutovacuumWorker{}
+		err := rows.Scan(&pid, &database, &text, &stateDuration, &worker.Started, &worker.Relid, &worker.Relation, &worker.Phase, &worker.Wraparound, &worker.Waiters, &worker.BlockingDDL)
+		Panic(err)
+		worker.Session = NewSession(pid, "", database, "localhost", "active", text, stateDuration, "")
+		worker.Session.BackendType = "autovacuum worker"
+		workers = append(workers, worker)
+	}
+
+	return workers
+}
+
+// RelationName returns the schema qualified name of a relation of the current database
+func (db *Db) RelationName(relid int64) (name string, err error) {
+	query := `select $1::oid::regclass::text;`
+	log.Debugf("query: %s\n", query)
+	err = db.conn.QueryRow(query, relid).Scan(&name)
+	return name, err
+}
+
+// CurrentDatabase returns the name of the database of the connection
+func (db *Db) CurrentDatabase() (name string, err error) {
+	query := `select current_database();`
+	log.Debugf("query: %s\n", query)
+	err = db.conn.QueryRow(query).Scan(&name)
+	return name, err
+}
+
+// SetIdleTimeout closes connections idle for longer than the timeout, so they don't prevent
+// databases from being dropped
+// Connections are opened again when needed.
+func (db *Db) SetIdleTimeout(timeout time.Duration) {
+	db.conn.SetConnMaxIdleTime(timeout)
+}
+
+// Roles returns roles by name with their attributes and memberships
+func (db *Db) Roles() map[string]*Role {
+	roles, err := db.QueryRoles()
+	Panic(err)
+	return roles
+}
+
+// QueryRoles returns roles by name or an error
+func (db *Db) QueryRoles() (roles map[string]*Role, err error) {
+	query := `with recursive memberships(member, roleid) as (
+	      select member, roleid from pg_catalog.pg_auth_members
+	      union
+	      select m.member, a.roleid from memberships m join pg_catalog.pg_auth_members a on a.member = m.roleid
+	 )
+	 select r.rolname as name,
+	        r.rolsuper as superuser,
+	        r.rolreplication as replication,
+	        coalesce(array_agg(g.rolname) filter (where g.rolname is not null), '{}') as "memberOf"
+	   from pg_catalog.pg_roles r
+	   left join memberships m on m.member = r.oid
+	   left join pg_catalog.pg_roles g on g.oid = m.roleid
+	  group by r.rolname, r.rolsuper, r.rolreplication;`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles = make(map[string]*Role)
+	for rows.Next() {
+		role := &Role{}
+		var memberOf pq.StringArray
+		if err = rows.Scan(&role.Name, &role.Superuser, &role.Replication, &memberOf); err != nil {
+			return nil, err
+		}
+		role.MemberOf = memberOf
+		roles[role.Name] = role
+	}
+
+	return roles, rows.Err()
+}
+
+// HbaFile returns the time configuration files were loaded and pg_hba.conf was modified
+func (db *Db) HbaFile() (file *HbaFile, err error) {
+	query := `select pg_conf_load_time() as loaded,
+	      (pg_stat_file(current_setting('hba_file'))).modification as modified;`
+	log.Debugf("query: %s\n", query)
+	file = &HbaFile{}
+	err = db.conn.QueryRow(query).Scan(&file.Loaded, &file.Modified)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// HbaRules returns rules of the pg_hba.conf file in the order they are evaluated
+func (db *Db) HbaRules() (rules []*HbaRule, err error) {
+	query := `select line_number as line,
+	      coalesce(type, '') as type,
+	      coalesce(database, '{}') as databases,
+	      coalesce(user_name, '{}') as users,
+	      coalesce(address, '') as address,
+	      coalesce(netmask, '') as netmask,
+	      coalesce(auth_method, '') as method,
+	      coalesce(error, '') as error
+	 from pg_catalog.pg_hba_file_rules;`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := &HbaRule{}
+		var databases, users pq.StringArray
+		err := rows.Scan(&rule.Line, &rule.Type, &databases, &users, &rule.Address, &rule.Netmask, &rule.Method, &rule.Error)
+		if err != nil {
+			return nil, err
+		}
+		rule.Databases = databases
+		rule.Users = users
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// Settings returns pgterminate settings defined on roles and databases
+// Invalid settings are returned as errors
+func (db *Db) Settings() (settings Settings, errs []error) {
+	query := `select coalesce(d.datname, '') as db,
+	      coalesce(r.rolname, '') as role,
+	      c.setting as setting
+	 from pg_catalog.pg_db_role_setting s
+	 cross join lateral unnest(s.setconfig) as c(setting)
+	 left join pg_catalog.pg_database d on d.oid = s.setdatabase
+	 left join pg_catalog.pg_roles r on r.oid = s.setrole
+	where c.setting like 'pgterminate.%';`
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	Panic(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var database, role, value string
+		err := rows.Scan(&database, &role, &value)
+		Panic(err)
+		setting, err := ParseSetting(database, role, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("role '%s' database '%s': %v", role, database, err))
+			continue
+		}
+		settings = append(settings, setting)
+	}
+
+	return settings, errs
+}
+
+// Policies returns enabled policies stored in a table ordered by priority
+// Errors are returned instead of terminating the program. Columns added after the first
+// release of the table are read through to_jsonb to support tables without them.
+func (db *Db) Policies(table string) (policies []*Policy, err error) {
+	var identifiers []string
+	for _, identifier := range strings.Split(table, ".") {
+		identifiers = append(identifiers, pq.QuoteIdentifier(identifier))
+	}
+	query := fmt.Sprintf(`select name as name,
+	      users as users,
+	      coalesce(users_regex, '') as "usersRegex",
+	      databases as databases,
+	      coalesce(databases_regex, '') as "databasesRegex",
+	      extract(epoch from active_timeout)::float8 as "activeTimeout",
+	      extract(epoch from idle_timeout)::float8 as "idleTimeout",
+	      coalesce(action, '') as action,
+	      coalesce(to_jsonb(p)->>'match', '') as match,
+	      coalesce(to_jsonb(p)->'labels', 'null')::text as labels,
+	      coalesce(to_jsonb(p)->'query_ids', 'null')::text as "queryIds",
+	      coalesce(to_jsonb(p)->>'query_ids_query', '') as "queryIdsQuery"
+	 from %s p
+	where enabled
+	order by priority, name;`, strings.Join(identifiers, "."))
+	log.Debugf("query: %s\n", query)
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		policy := &Policy{}
+		var users, databases pq.StringArray
+		var activeTimeout, idleTimeout sql.NullFloat64
+		var labels, queryIDs, queryIDsQuery string
+		err := rows.Scan(&policy.Name, &users, &policy.UsersRegex, &databases, &policy.DatabasesRegex, &activeTimeout, &idleTimeout, &policy.Action, &policy.MatchExpression, &labels, &queryIDs, &queryIDsQuery)
+		if err != nil {
+			return nil, err
+		}
+		// Queries would be executed as pgterminate, so they are not accepted from the table
+		if queryIDsQuery != "" {
+			return nil, fmt.Errorf("policy %s: query_ids_query is only accepted in the configuration file", policy.Name)
+		}
+		if err = json.Unmarshal([]byte(labels), &policy.Labels); err != nil {
+			return nil, fmt.Errorf("policy %s: labels: %v", policy.Name, err)
+		}
+		if err = json.Unmarshal([]byte(queryIDs), &policy.QueryIDs); err != nil {
+			return nil, fmt.Errorf("policy %s: query_ids: %v", policy.Name, err)
+		}
+		policy.Users = users
+		policy.Databases = databases
+		if activeTimeout.Valid {
+			policy.ActiveTimeout = &activeTimeout.Float64
+		}
+		if idleTimeout.Valid {
+			policy.IdleTimeout = &idleTimeout.Float64
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+// QueryIDs executes a query returning query identifiers in its first column, like
+// "select queryid from pg_stat_statements where ..."
+// The query is prepared, so it can't contain several statements, and runs in a read-only
+// transaction, rolled back, with a statement timeout
+func (db *Db) QueryIDs(query string, timeout time.Duration) (ids []int64, err error) {
+	tx, err := db.conn.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	setting := fmt.Sprintf(`set local statement_timeout = %d;`, timeout.Milliseconds())
+	log.Debugf("query: %s\n", setting)
+	if _, err = tx.Exec(setting); err != nil {
+		return nil, err
+	}
+
+	// Preparing uses the extended query protocol, which rejects several statements, like a
+	// commit ending the read-only transaction
+	log.Debugf("query: %s\n", query)
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id sql.NullInt64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if id.Valid {
+			ids = append(ids, id.Int64)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// ConnectionLimit returns the connection limit of a role, -1 meaning no limit
+func (db *Db) ConnectionLimit(role string) (limit int, err error) {
+	query := `select rolconnlimit from pg_catalog.pg_roles where rolname = $1;`
+	log.Debugf("query: %s\n", query)
+	err = db.conn.QueryRow(query, role).Scan(&limit)
+	return limit, err
+}
+
+// SetConnectionLimit changes the connection limit of a role, -1 meaning no limit
+func (db *Db) SetConnectionLimit(role string, limit int) error {
+	query := fmt.Sprintf(`alter role %s connection limit %d;`, pq.QuoteIdentifier(role), limit)
+	log.Debugf("query: %s\n", query)
+	_, err := db.conn.Exec(query)
+	return err
+}
+
+// AllowConnections returns true when a database allows connections
+func (db *Db) AllowConnections(database string) (allowed bool, err error) {
+	query := `select datallowconn from pg_catalog.pg_database where datname = $1;`
+	log.Debugf("query: %s\n", query)
+	err = db.conn.QueryRow(query, database).Scan(&allowed)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("database %s does not exist", database)
+	}
+	return allowed, err
+}
+
+// SetAllowConnections allows or blocks new connections to a database
+func (db *Db) SetAllowConnections(database string, allowed bool) error {
+	query := fmt.Sprintf(`alter database %s allow_connections %t;`, pq.QuoteIdentifier(database), allowed)
+	log.Debugf("query: %s\n", query)
+	_, err := db.conn.Exec(query)
+	return err
+}
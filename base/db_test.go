@@ -0,0 +1,80 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestWalFunction(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		want    string
+	}{
+		{"pg_current_wal_lsn", 100000, "pg_current_wal_lsn"},
+		{"pg_current_wal_lsn", 90600, "pg_current_xlog_location"},
+		{"pg_last_wal_receive_lsn", 90600, "pg_last_xlog_receive_location"},
+		{"pg_last_wal_replay_lsn", 90600, "pg_last_xlog_replay_location"},
+		{"pg_wal_lsn_diff", 90600, "pg_xlog_location_diff"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			got := walFunction(tc.version, tc.name)
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			} else {
+				t.Logf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBackendType(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		want    string
+	}{
+		{"Column", 100000, "coalesce(a.backend_type, '')"},
+		{"Emulated", 90600, `case when a.query like 'autovacuum:%' then 'autovacuum worker'
+	            when a.pid in (select pid from pg_catalog.pg_stat_replication) then 'walsender'
+	            else 'client backend' end`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := backendType(tc.version, "a.")
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			} else {
+				t.Logf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReplayLagQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		want    string
+	}{
+		{"WAL functions", 100000, `select case when pg_last_wal_receive_lsn() is null or pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0
+	      else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)
+	      end as lag;`},
+		{"xlog functions", 90600, `select case when pg_last_xlog_receive_location() is null or pg_last_xlog_receive_location() = pg_last_xlog_replay_location() then 0
+	      else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)
+	      end as lag;`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := replayLagQuery(tc.version)
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			} else {
+				t.Logf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
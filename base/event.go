@@ -0,0 +1,49 @@
+package base
+
+// Events describe why a session has been sent to notifiers
+const (
+	// ActiveEvent for sessions active for too long
+	ActiveEvent = "active"
+	// IdleEvent for sessions idle for too long
+	IdleEvent = "idle"
+	// WalsenderEvent for replication sessions lagging behind
+	WalsenderEvent = "walsender"
+	// SlotEvent for replication slots retaining too much WAL
+	SlotEvent = "slot"
+	// StandbyEvent for queries cancelled because of replay lag on standbys
+	StandbyEvent = "standby"
+	// LockQueueEvent for DDL cancelled or sessions blocking DDL terminated because of a lock queue
+	LockQueueEvent = "lock-queue"
+	// RelationEvent for sessions holding or waiting for locks on relations
+	RelationEvent = "relation"
+	// AdvisoryLockEvent for sessions holding advisory locks for too long
+	AdvisoryLockEvent = "advisory-lock"
+	// ProtectedEvent for sessions that would have been terminated but are protected
+	ProtectedEvent = "protected"
+	// HungBackupEvent for protected backups running longer than the backup timeout
+	HungBackupEvent = "hung-backup"
+	// AutovacuumEvent for autovacuum workers cancelled by the autovacuum policy
+	AutovacuumEvent = "autovacuum"
+	// SecurityEvent for sessions not meeting the security requirement
+	SecurityEvent = "security"
+	// SweepEvent for sessions of disabled roles or databases not allowing connections
+	SweepEvent = "sweep"
+	// HbaEvent for sessions rejected by current pg_hba.conf rules
+	HbaEvent = "hba"
+	// ResourceEvent for queries cancelled because their backend used too many resources
+	ResourceEvent = "resource"
+	// AdaptiveEvent for queries cancelled because they run longer than usual for their fingerprint
+	AdaptiveEvent = "adaptive"
+	// RepeatOffenderEvent for clients killed too many times in a window
+	RepeatOffenderEvent = "repeat-offender"
+	// ThrottleEvent for connection limits of repeat offenders lowered
+	ThrottleEvent = "throttle"
+	// UnthrottleEvent for connection limits of repeat offenders restored after the cool-down
+	UnthrottleEvent = "unthrottle"
+	// DrainEvent for databases blocked and sessions killed by the drain command
+	DrainEvent = "drain"
+	// UndrainEvent for databases allowing connections again after a drain
+	UndrainEvent = "undrain"
+	// KillEvent for sessions killed by the kill command
+	KillEvent = "kill"
+)
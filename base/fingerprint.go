@@ -0,0 +1,152 @@
+package base
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// listRegex matches lists of normalized values like "(?, ?, ?)"
+var listRegex = regexp.MustCompile(`\(\?(, \?)+\)`)
+
+// operatorRunes are characters of SQL operators
+const operatorRunes = "+-*/<>=~!@#%^&|`?:"
+
+// Fingerprint returns the identifier of a query and the normalized query
+// The identifier is the query_id in hexadecimal when computed by PostgreSQL, or a hash of
+// the normalized query otherwise
+func Fingerprint(queryID int64, query string) (string, string) {
+	normalized := NormalizeQuery(query)
+	if queryID != 0 {
+		return fmt.Sprintf("%016x", uint64(queryID)), normalized
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", hash.Sum64()), normalized
+}
+
+// NormalizeQuery replaces literals and parameters by "?", removes comments, collapses lists
+// of values and whitespaces and lowers the case of keywords and identifiers not quoted
+func NormalizeQuery(query string) string {
+	return listRegex.ReplaceAllString(joinTokens(queryTokens([]rune(query))), "(?)")
+}
+
+// queryTokens splits a query into normalized tokens
+func queryTokens(runes []rune) (tokens []string) {
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && next == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && next == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end == -1 {
+				return tokens
+			}
+			i += 2 + len([]rune(string(runes[i+2:])[:end])) + 2
+		case r == '\'' || ((r == 'e' || r == 'E') && next == '\''):
+			escapes := r != '\''
+			if escapes {
+				i++
+			}
+			i = stringEnd(runes, i, escapes)
+			tokens = append(tokens, "?")
+		case r == '$':
+			end := i + 1
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			if end < len(runes) && runes[end] == '$' && !unicode.IsDigit(next) {
+				// Dollar-quoted string like $$...$$ or $tag$...$tag$
+				tag := string(runes[i : end+1])
+				rest := string(runes[end+1:])
+				closing := strings.Index(rest, tag)
+				if closing == -1 {
+					i = len(runes)
+				} else {
+					i = end + 1 + len([]rune(rest[:closing])) + len([]rune(tag))
+				}
+			} else {
+				// Parameter like $1
+				i = end
+			}
+			tokens = append(tokens, "?")
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end < len(runes) {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(next)):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, "?")
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_' || runes[end] == '$') {
+				end++
+			}
+			tokens = append(tokens, strings.ToLower(string(runes[i:end])))
+			i = end
+		case strings.ContainsRune(operatorRunes, r):
+			end := i
+			for end < len(runes) && strings.ContainsRune(operatorRunes, runes[end]) {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		default:
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+	return tokens
+}
+
+// stringEnd returns the position following the end of the string literal starting at i
+// Backslashes escape characters in escape strings like E'...' only, because
+// standard_conforming_strings is enabled by default
+func stringEnd(runes []rune, i int, escapes bool) int {
+	for i++; i < len(runes); i++ {
+		switch {
+		case escapes && runes[i] == '\\':
+			i++
+		case runes[i] == '\'' && i+1 < len(runes) && runes[i+1] == '\'':
+			i++
+		case runes[i] == '\'':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// joinTokens joins tokens with spaces except around dots, inside parentheses and before
+// commas and semicolons
+func joinTokens(tokens []string) string {
+	var output strings.Builder
+	for i, token := range tokens {
+		if i > 0 {
+			previous := tokens[i-1]
+			if previous != "(" && previous != "." && token != ")" && token != "," && token != "." && token != ";" {
+				output.WriteRune(' ')
+			}
+		}
+		output.WriteString(token)
+	}
+	return output.String()
+}
@@ -0,0 +1,51 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"Literals", "SELECT * FROM users WHERE id = 42 AND name = 'o''brien'", "select * from users where id = ? and name = ?"},
+		{"Parameters and lists", "select a.b from t a where a.id in (1, 2, 3) and x > $1", "select a.b from t a where a.id in (?) and x > ?"},
+		{"Comments and whitespaces", "/* app */ select\n\tcount(*)  -- total\nfrom t", "select count (*) from t"},
+		{"Quoted identifiers", `select "Name" from "Users" where note = E'it\'s'`, `select "Name" from "Users" where note = ?`},
+		{"Backslashes in standard strings", `select * from files where path = 'C:\' and id = 1`, "select * from files where path = ? and id = ?"},
+		{"Dollar quotes", "select $tag$a 'b' c$tag$, $$d$$, 1.5e3", "select ?, ?, ?"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NormalizeQuery(tc.query)
+			if got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			} else {
+				t.Logf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	first, _ := Fingerprint(0, "select * from t where id = 1")
+	second, _ := Fingerprint(0, "SELECT * FROM t WHERE id = 2")
+	if first != second {
+		t.Errorf("got %s and %s; want same fingerprints for queries differing by literals", first, second)
+	}
+
+	other, _ := Fingerprint(0, "select * from u where id = 1")
+	if first == other {
+		t.Errorf("got %s and %s; want different fingerprints for different queries", first, other)
+	}
+
+	got, _ := Fingerprint(-1, "select 1")
+	if want := "ffffffffffffffff"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	} else {
+		t.Logf("got %s; want %s", got, want)
+	}
+}
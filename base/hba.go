@@ -0,0 +1,182 @@
+package base
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HbaRule represents a rule of pg_hba.conf as reported by pg_hba_file_rules
+type HbaRule struct {
+	Line      int
+	Type      string
+	Databases []string
+	Users     []string
+	Address   string
+	Netmask   string
+	Method    string
+	Error     string
+}
+
+// HbaFile represents the state of the pg_hba.conf file compared to the loaded configuration
+type HbaFile struct {
+	Loaded   time.Time
+	Modified time.Time
+}
+
+// Reloaded returns true when the configuration has been loaded after a previous load time
+// and the file has not been modified since, so the file on disk shows the loaded rules
+func (f *HbaFile) Reloaded(previous time.Time) bool {
+	return f.Loaded.After(previous) && !f.Modified.After(f.Loaded)
+}
+
+// String returns a HbaRule as a reason
+func (r *HbaRule) String() string {
+	return fmt.Sprintf("line=%d method=%s", r.Line, r.Method)
+}
+
+// Match returns true when the rule matches the connection of a session
+// An error is returned when the rule can't be evaluated, like host names or "samenet"
+// addresses, because the outcome of the whole rule list is unknown from there
+func (r *HbaRule) Match(e *Encryption, role *Role) (bool, error) {
+	if !r.matchType(e) {
+		return false, nil
+	}
+	database, err := r.matchDatabase(e.Session, role)
+	if err != nil || !database {
+		return false, err
+	}
+	user, err := r.matchUser(e.Session, role)
+	if err != nil || !user {
+		return false, err
+	}
+	if e.Local {
+		return true, nil
+	}
+	return r.matchAddress(e.Session.ClientAddr())
+}
+
+// matchType returns true when the connection type matches the rule type
+func (r *HbaRule) matchType(e *Encryption) bool {
+	switch r.Type {
+	case "local":
+		return e.Local
+	case "host":
+		return !e.Local
+	case "hostssl":
+		return !e.Local && e.SSL
+	case "hostnossl":
+		return !e.Local && !e.SSL
+	case "hostgssenc":
+		return !e.Local && e.GSSEncrypted
+	case "hostnogssenc":
+		return !e.Local && !e.GSSEncrypted
+	}
+	return false
+}
+
+// matchDatabase returns true when the session database is one of the rule databases
+// Physical replication sessions only match the "replication" keyword
+func (r *HbaRule) matchDatabase(session *Session, role *Role) (bool, error) {
+	physical := session.BackendType == "walsender" && session.Db == ""
+	for _, database := range r.Databases {
+		if physical {
+			if database == "replication" {
+				return true, nil
+			}
+			continue
+		}
+		switch {
+		case database == "all":
+			return true, nil
+		case database == "sameuser":
+			if session.Db == session.User {
+				return true, nil
+			}
+		case database == "samerole" || database == "samegroup":
+			if role != nil && InSlice(session.Db, role.MemberOf) {
+				return true, nil
+			}
+		case database == "replication":
+			continue
+		case strings.HasPrefix(database, "/"):
+			matched, err := regexp.MatchString(database[1:], session.Db)
+			if err != nil || matched {
+				return matched, err
+			}
+		case database == session.Db:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchUser returns true when the session user is one of the rule users or a member of one
+// of the rule groups
+func (r *HbaRule) matchUser(session *Session, role *Role) (bool, error) {
+	for _, user := range r.Users {
+		switch {
+		case user == "all":
+			return true, nil
+		case strings.HasPrefix(user, "+"):
+			group := user[1:]
+			if session.User == group || (role != nil && InSlice(group, role.MemberOf)) {
+				return true, nil
+			}
+		case strings.HasPrefix(user, "/"):
+			matched, err := regexp.MatchString(user[1:], session.User)
+			if err != nil || matched {
+				return matched, err
+			}
+		case user == session.User:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchAddress returns true when the client address is in the rule network
+func (r *HbaRule) matchAddress(address string) (bool, error) {
+	if r.Address == "" || r.Address == "all" {
+		return true, nil
+	}
+	if r.Address == "samehost" || r.Address == "samenet" {
+		return false, fmt.Errorf("line %d: %s addresses can't be evaluated", r.Line, r.Address)
+	}
+	network := net.ParseIP(r.Address)
+	if network == nil {
+		return false, fmt.Errorf("line %d: host name %s can't be evaluated", r.Line, r.Address)
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false, fmt.Errorf("line %d: invalid client address %s", r.Line, address)
+	}
+	if (network.To4() == nil) != (ip.To4() == nil) {
+		return false, nil
+	}
+	mask := net.ParseIP(r.Netmask)
+	if mask == nil {
+		return network.Equal(ip), nil
+	}
+	if network.To4() != nil {
+		network, ip, mask = network.To4(), ip.To4(), mask.To4()
+	}
+	return network.Mask(net.IPMask(mask)).Equal(ip.Mask(net.IPMask(mask))), nil
+}
+
+// EvaluateHba returns the first rule matching the connection of a session or nil when no
+// rule matches, which means the connection is rejected
+func EvaluateHba(rules []*HbaRule, e *Encryption, role *Role) (*HbaRule, error) {
+	for _, rule := range rules {
+		matched, err := rule.Match(e, role)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package base
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateHba(t *testing.T) {
+	rules := []*HbaRule{
+		{Line: 1, Type: "local", Databases: []string{"all"}, Users: []string{"postgres"}, Method: "peer"},
+		{Line: 2, Type: "host", Databases: []string{"replication"}, Users: []string{"replicator"}, Address: "10.0.0.0", Netmask: "255.0.0.0", Method: "scram-sha-256"},
+		{Line: 3, Type: "hostssl", Databases: []string{"warehouse"}, Users: []string{"+analysts"}, Address: "10.0.0.0", Netmask: "255.0.0.0", Method: "scram-sha-256"},
+		{Line: 4, Type: "host", Databases: []string{"sameuser"}, Users: []string{"all"}, Address: "all", Method: "scram-sha-256"},
+		{Line: 5, Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "192.168.0.0", Netmask: "255.255.0.0", Method: "reject"},
+		{Line: 6, Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "::1", Netmask: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", Method: "trust"},
+	}
+	analyst := &Role{Name: "alice", MemberOf: []string{"analysts"}}
+
+	tests := []struct {
+		name       string
+		encryption *Encryption
+		role       *Role
+		want       int
+	}{
+		{"Local", &Encryption{Session: &Session{User: "postgres", Db: "postgres"}, Local: true}, nil, 1},
+		{"Local rejected", &Encryption{Session: &Session{User: "alice", Db: "postgres"}, Local: true}, nil, 0},
+		{"Physical replication", &Encryption{Session: &Session{User: "replicator", Client: "10.0.0.2:5432", BackendType: "walsender"}}, nil, 2},
+		{"Group over SSL", &Encryption{Session: &Session{User: "alice", Db: "warehouse", Client: "10.1.1.1:5432"}, SSL: true}, analyst, 3},
+		{"Group without SSL", &Encryption{Session: &Session{User: "alice", Db: "warehouse", Client: "10.1.1.1:5432"}}, analyst, 0},
+		{"Not a member", &Encryption{Session: &Session{User: "bob", Db: "warehouse", Client: "10.1.1.1:5432"}, SSL: true}, &Role{Name: "bob"}, 0},
+		{"Same user", &Encryption{Session: &Session{User: "bob", Db: "bob", Client: "10.1.1.1:5432"}}, nil, 4},
+		{"Reject", &Encryption{Session: &Session{User: "bob", Db: "warehouse", Client: "192.168.1.1:5432"}}, nil, 5},
+		{"IPv6", &Encryption{Session: &Session{User: "bob", Db: "warehouse", Client: "::1:5432"}}, nil, 6},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := EvaluateHba(rules, tc.encryption, tc.role)
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			got := 0
+			if rule != nil {
+				got = rule.Line
+			}
+			if got != tc.want {
+				t.Errorf("got line %d; want line %d", got, tc.want)
+			} else {
+				t.Logf("got line %d; want line %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateHbaErrors(t *testing.T) {
+	encryption := &Encryption{Session: &Session{User: "bob", Db: "bob", Client: "10.0.0.1:5432"}}
+
+	tests := []struct {
+		name string
+		rule *HbaRule
+	}{
+		{"Host name", &HbaRule{Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: ".example.com"}},
+		{"Same network", &HbaRule{Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "samenet"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := EvaluateHba([]*HbaRule{tc.rule}, encryption, nil)
+			if err == nil {
+				t.Errorf("got no error; want error")
+			} else {
+				t.Logf("got error %v; want error", err)
+			}
+		})
+	}
+}
+
+func TestHbaFileReloaded(t *testing.T) {
+	loaded := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		file     *HbaFile
+		previous time.Time
+		want     bool
+	}{
+		{"First check", &HbaFile{Loaded: loaded, Modified: loaded.Add(-time.Hour)}, time.Time{}, true},
+		{"Not reloaded", &HbaFile{Loaded: loaded, Modified: loaded.Add(-time.Hour)}, loaded, false},
+		{"Reloaded", &HbaFile{Loaded: loaded, Modified: loaded.Add(-time.Hour)}, loaded.Add(-time.Minute), true},
+		{"Modified after reload", &HbaFile{Loaded: loaded, Modified: loaded.Add(time.Minute)}, loaded.Add(-time.Minute), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.file.Reloaded(tc.previous)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
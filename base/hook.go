@@ -0,0 +1,265 @@
+package base
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/jouir/pgterminate/log"
+)
+
+// Decisions returned by the policy hook
+const (
+	// TerminateDecision terminates the session
+	TerminateDecision = "terminate"
+	// CancelDecision cancels the query of the session
+	CancelDecision = "cancel"
+	// SkipDecision leaves the session alone for its whole lifetime
+	SkipDecision = "skip"
+	// PostponeDecision leaves the session alone until the next iteration
+	PostponeDecision = "postpone"
+)
+
+// Modes of the policy hook when it doesn't answer in time
+const (
+	// FailOpen handles sessions without decision like there was no hook
+	FailOpen = "open"
+	// FailClosed leaves sessions without decision alone
+	FailClosed = "closed"
+)
+
+// HookRequest is sent to the policy hook for each candidate session
+type HookRequest struct {
+	ID              int64             `json:"id"`
+	Event           string            `json:"event"`
+	Pid             int64             `json:"pid"`
+	User            string            `json:"user"`
+	Db              string            `json:"db"`
+	Client          string            `json:"client"`
+	State           string            `json:"state"`
+	Query           string            `json:"query"`
+	StateDuration   float64           `json:"state_duration"`
+	ApplicationName string            `json:"application_name"`
+	BackendType     string            `json:"backend_type"`
+	Policy          string            `json:"policy,omitempty"`
+	Reason          string            `json:"reason,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// Decision is sent by the policy hook to answer a request with the same identifier
+type Decision struct {
+	ID       int64  `json:"id"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+// Hook represents an external program deciding what to do with sessions
+// Requests are written as JSON lines on its standard input and decisions are read as
+// JSON lines from its standard output
+type Hook struct {
+	command   string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	decisions chan *Decision
+	sequence  int64
+}
+
+// NewHook instanciates a Hook
+func NewHook(command string) *Hook {
+	return &Hook{command: command}
+}
+
+// Start runs the hook program with a shell
+func (h *Hook) Start() error {
+	cmd := exec.Command("/bin/sh", "-c", h.command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	log.Infof("Policy hook started with pid %d\n", cmd.Process.Pid)
+
+	decisions := make(chan *Decision, 100)
+	go func() {
+		defer close(decisions)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			decision := &Decision{}
+			if err := json.Unmarshal(scanner.Bytes(), decision); err != nil {
+				log.Warnf("Policy hook: ignoring invalid line %q: %v\n", scanner.Text(), err)
+				continue
+			}
+			decisions <- decision
+		}
+		if err := cmd.Wait(); err != nil {
+			log.Errorf("Policy hook exited: %v\n", err)
+		} else {
+			log.Warn("Policy hook exited")
+		}
+	}()
+
+	h.cmd = cmd
+	h.stdin = stdin
+	h.decisions = decisions
+	return nil
+}
+
+// Stop closes the standard input of the hook program and kills it when it doesn't exit
+// by itself
+func (h *Hook) Stop() {
+	if h.cmd == nil {
+		return
+	}
+	h.stdin.Close()
+	select {
+	case <-h.wait():
+	case <-time.After(time.Second):
+		h.cmd.Process.Kill()
+	}
+	h.cmd = nil
+	h.decisions = nil
+}
+
+// wait returns a channel closed when the hook program has exited
+func (h *Hook) wait() chan bool {
+	done := make(chan bool)
+	go func(decisions chan *Decision) {
+		for range decisions {
+		}
+		close(done)
+	}(h.decisions)
+	return done
+}
+
+// Decide sends sessions to the hook program and returns decisions received before the
+// timeout by pid
+// The hook program is (re)started when it isn't running, and stopped to be restarted when it
+// doesn't read requests before the timeout. An error is returned when some sessions have no
+// valid decision.
+func (h *Hook) Decide(sessions []*Session, event string, timeout time.Duration) (map[int64]*Decision, error) {
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	// Discard late decisions from previous requests
+	h.discard()
+
+	if h.cmd == nil {
+		if err := h.Start(); err != nil {
+			return nil, err
+		}
+	}
+
+	var requests []byte
+	pids := make(map[int64]int64)
+	for _, session := range sessions {
+		h.sequence++
+		request := &HookRequest{
+			ID:              h.sequence,
+			Event:           event,
+			Pid:             session.Pid,
+			User:            session.User,
+			Db:              session.Db,
+			Client:          session.Client,
+			State:           session.State,
+			Query:           session.Query,
+			StateDuration:   session.StateDuration,
+			ApplicationName: session.ApplicationName,
+			BackendType:     session.BackendType,
+			Reason:          session.Reason,
+			Labels:          session.Labels,
+		}
+		if session.Policy != nil {
+			request.Policy = session.Policy.Name
+		}
+		line, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(append(requests, line...), '\n')
+		pids[request.ID] = session.Pid
+	}
+
+	// Writing blocks when the hook program doesn't read its standard input, so requests are
+	// written in background under the same deadline as decisions
+	deadline := time.After(timeout)
+	written := make(chan error, 1)
+	go func(stdin io.Writer) {
+		_, err := stdin.Write(requests)
+		written <- err
+	}(h.stdin)
+	select {
+	case err := <-written:
+		if err != nil {
+			h.Stop()
+			return nil, err
+		}
+	case <-deadline:
+		h.Stop()
+		return nil, fmt.Errorf("hook didn't read %d session(s) in time, restarting it", len(pids))
+	}
+
+	decisions := make(map[int64]*Decision)
+	for len(pids) > 0 {
+		select {
+		case decision, ok := <-h.decisions:
+			if !ok {
+				h.exited()
+				return decisions, fmt.Errorf("hook exited before answering %d session(s)", len(pids))
+			}
+			pid, found := pids[decision.ID]
+			if !found {
+				continue
+			}
+			if !ValidDecision(decision.Decision) {
+				log.Warnf("Policy hook: ignoring invalid decision %q for pid %d\n", decision.Decision, pid)
+				continue
+			}
+			delete(pids, decision.ID)
+			decisions[pid] = decision
+		case <-deadline:
+			return decisions, fmt.Errorf("hook didn't answer %d session(s) in time", len(pids))
+		}
+	}
+	return decisions, nil
+}
+
+// discard drops decisions waiting to be read
+func (h *Hook) discard() {
+	for {
+		select {
+		case decision, ok := <-h.decisions:
+			if !ok {
+				h.exited()
+				return
+			}
+			log.Debugf("Policy hook: discarding late decision for request %d\n", decision.ID)
+		default:
+			return
+		}
+	}
+}
+
+// exited releases resources of a hook program that has exited
+func (h *Hook) exited() {
+	if h.cmd != nil {
+		h.stdin.Close()
+		h.cmd = nil
+		h.decisions = nil
+	}
+}
+
+// ValidDecision returns true when the decision is known
+func ValidDecision(decision string) bool {
+	return decision == TerminateDecision || decision == CancelDecision || decision == SkipDecision || decision == PostponeDecision
+}
@@ -0,0 +1,88 @@
+package base
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoHook answers each request with the decision found in the application name
+const echoHook = `while read -r line; do
+	id=$(echo "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+	app=$(echo "$line" | sed 's/.*"application_name":"\([^"]*\)".*/\1/')
+	echo "{\"id\":$id,\"decision\":\"$app\",\"reason\":\"hook\"}"
+done`
+
+func TestHookDecide(t *testing.T) {
+	sessions := []*Session{
+		{Pid: 1, ApplicationName: TerminateDecision},
+		{Pid: 2, ApplicationName: CancelDecision},
+		{Pid: 3, ApplicationName: SkipDecision},
+		{Pid: 4, ApplicationName: "invalid"},
+	}
+
+	hook := NewHook(echoHook)
+	defer hook.Stop()
+
+	decisions, err := hook.Decide(sessions, ActiveEvent, 500*time.Millisecond)
+	if err == nil {
+		t.Errorf("got no error; want error for invalid decision")
+	}
+
+	tests := []struct {
+		name string
+		pid  int64
+		want string
+	}{
+		{"Terminate", 1, TerminateDecision},
+		{"Cancel", 2, CancelDecision},
+		{"Skip", 3, SkipDecision},
+		{"Invalid", 4, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			if decision, ok := decisions[tc.pid]; ok {
+				got = decision.Decision
+			}
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			} else {
+				t.Logf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHookRestart(t *testing.T) {
+	hook := NewHook("read -r line; exit 1")
+	defer hook.Stop()
+
+	sessions := []*Session{{Pid: 1}}
+	for i := 0; i < 2; i++ {
+		decisions, err := hook.Decide(sessions, ActiveEvent, time.Second)
+		if err == nil || len(decisions) != 0 {
+			t.Errorf("got %d decision(s) and error %v; want no decision and error", len(decisions), err)
+		}
+	}
+}
+
+func TestHookWriteTimeout(t *testing.T) {
+	// The hook never reads its standard input, requests larger than the pipe buffer block
+	hook := NewHook("exec sleep 60")
+	defer hook.Stop()
+
+	sessions := []*Session{{Pid: 1, Query: strings.Repeat("x", 1<<20)}}
+	start := time.Now()
+	decisions, err := hook.Decide(sessions, ActiveEvent, 500*time.Millisecond)
+	if err == nil || len(decisions) != 0 {
+		t.Errorf("got %d decision(s) and error %v; want no decision and error", len(decisions), err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("got Decide returning after %s; want it bounded by the timeout", elapsed)
+	}
+	if hook.cmd != nil {
+		t.Errorf("got hook still running; want hook stopped to be restarted")
+	}
+}
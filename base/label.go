@@ -0,0 +1,96 @@
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// labelPlaceholderRegex matches label placeholders like %{label:team}
+var labelPlaceholderRegex = regexp.MustCompile(`%\{label:([^}]*)\}`)
+
+// LabelParser turns application names into labels
+type LabelParser interface {
+	Parse(applicationName string) map[string]string
+}
+
+// NewLabelParser returns a parser splitting key/value pairs when separator is set, a parser
+// using named groups of regex when regex is set, or nil when labels are disabled
+func NewLabelParser(separator string, assignment string, regex string) (LabelParser, error) {
+	switch {
+	case separator != "" && regex != "":
+		return nil, fmt.Errorf("labels separator and labels regex are mutually exclusive")
+	case separator != "":
+		if assignment == "" {
+			return nil, fmt.Errorf("labels assignment is required with labels separator")
+		}
+		return &keyValueParser{separator: separator, assignment: assignment}, nil
+	case regex != "":
+		compiled, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, err
+		}
+		named := false
+		for _, name := range compiled.SubexpNames() {
+			if name != "" {
+				named = true
+			}
+		}
+		if !named {
+			return nil, fmt.Errorf("labels regex requires at least one named group like (?P<team>...)")
+		}
+		return &regexParser{regex: compiled}, nil
+	}
+	return nil, nil
+}
+
+// keyValueParser parses application names like "svc=billing;team=payments"
+type keyValueParser struct {
+	separator  string
+	assignment string
+}
+
+// Parse returns labels from pairs, pairs without assignment are ignored
+func (p *keyValueParser) Parse(applicationName string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(applicationName, p.separator) {
+		parts := strings.SplitN(pair, p.assignment, 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if key := strings.TrimSpace(parts[0]); key != "" {
+			labels[key] = strings.TrimSpace(parts[1])
+		}
+	}
+	return labels
+}
+
+// regexParser parses application names with named groups of a regex
+type regexParser struct {
+	regex *regexp.Regexp
+}
+
+// Parse returns non-empty named groups as labels
+func (p *regexParser) Parse(applicationName string) map[string]string {
+	labels := make(map[string]string)
+	match := p.regex.FindStringSubmatch(applicationName)
+	if match == nil {
+		return labels
+	}
+	for i, name := range p.regex.SubexpNames() {
+		if name != "" && match[i] != "" {
+			labels[name] = match[i]
+		}
+	}
+	return labels
+}
+
+// MatchLabels returns true when all expected labels have the same value in labels
+func MatchLabels(expected map[string]string, labels map[string]string) bool {
+	for key, value := range expected {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
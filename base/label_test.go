@@ -0,0 +1,73 @@
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelParser(t *testing.T) {
+	tests := []struct {
+		name            string
+		separator       string
+		assignment      string
+		regex           string
+		applicationName string
+		want            map[string]string
+	}{
+		{"Key values", ";", "=", "", "svc=billing;team=payments;env=prod", map[string]string{"svc": "billing", "team": "payments", "env": "prod"}},
+		{"Spaces and invalid pairs", ",", ":", "", " team : payments , psql ,:empty", map[string]string{"team": "payments"}},
+		{"Regex", "", "", "^(?P<svc>[a-z]+)-(?P<env>prod|staging)$", "billing-prod", map[string]string{"svc": "billing", "env": "prod"}},
+		{"Regex without match", "", "", "^(?P<svc>[a-z]+)-(?P<env>prod|staging)$", "psql", map[string]string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parser, err := NewLabelParser(tc.separator, tc.assignment, tc.regex)
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			got := parser.Parse(tc.applicationName)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewLabelParserErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		separator  string
+		assignment string
+		regex      string
+	}{
+		{"Separator and regex", ";", "=", "(?P<team>.*)"},
+		{"Missing assignment", ";", "", ""},
+		{"Invalid regex", "", "", "("},
+		{"Regex without named group", "", "", "^(.*)$"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewLabelParser(tc.separator, tc.assignment, tc.regex)
+			if err == nil {
+				t.Errorf("got no error; want error")
+			} else {
+				t.Logf("got error %v; want error", err)
+			}
+		})
+	}
+}
+
+func TestSessionFormatLabels(t *testing.T) {
+	session := &Session{User: "test", Labels: map[string]string{"team": "payments"}}
+	got := session.Format("user=%u team=%{label:team} env=%{label:env}")
+	want := "user=test team=payments env="
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	} else {
+		t.Logf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package base
+
+// LockQueue represents a DDL waiting for a relation lock with sessions queued behind it
+type LockQueue struct {
+	Pid      int64
+	Relation string
+	Mode     string
+	Blockers []int64
+	Waiters  int
+}
+
+// RelationLock represents a lock held or awaited by a session on a relation
+type RelationLock struct {
+	Pid      int64
+	Db       string
+	Schema   string
+	Relation string
+	Mode     string
+	Granted  bool
+}
+
+// Name returns the schema qualified name of the relation
+func (l *RelationLock) Name() string {
+	return l.Schema + "." + l.Relation
+}
+
+// String returns the relation and the lock mode
+func (l *RelationLock) String() string {
+	if l.Granted {
+		return l.Name() + ":" + l.Mode
+	}
+	return l.Name() + ":" + l.Mode + ":waiting"
+}
+
+// Actions taken when a lock queue exceeds its size
+const (
+	// CancelDDL cancels the DDL waiting for the lock so it can be retried later
+	CancelDDL = "cancel-ddl"
+	// TerminateBlockers terminates sessions blocking the DDL
+	TerminateBlockers = "terminate-blockers"
+)
@@ -0,0 +1,402 @@
+package base
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a boolean expression evaluated against sessions
+//
+// Grammar:
+//
+//	expression := term { "or" term }
+//	term       := factor { "and" factor }
+//	factor     := "not" factor | "(" expression ")" | comparison
+//	comparison := field operator value
+//	operator   := "=" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~" | "in"
+//
+// Strings are quoted with single or double quotes. Numbers accept PostgreSQL time units
+// like "5min" and are converted to seconds. "~" and "!~" match regexes, "in" matches
+// client addresses against a CIDR like '10.0.0.0/8'.
+type Expression interface {
+	Eval(session *Session) bool
+}
+
+// fieldKind is the type of a session field
+type fieldKind int
+
+const (
+	stringField fieldKind = iota
+	numberField
+)
+
+// field describes how to read a session field
+type field struct {
+	kind   fieldKind
+	string func(s *Session) string
+	number func(s *Session) float64
+}
+
+// fields lists session fields available in expressions
+var fields = map[string]field{
+	"pid":              {kind: numberField, number: func(s *Session) float64 { return float64(s.Pid) }},
+	"user":             {kind: stringField, string: func(s *Session) string { return s.User }},
+	"db":               {kind: stringField, string: func(s *Session) string { return s.Db }},
+	"client":           {kind: stringField, string: func(s *Session) string { return s.Client }},
+	"client_addr":      {kind: stringField, string: func(s *Session) string { return s.ClientAddr() }},
+	"state":            {kind: stringField, string: func(s *Session) string { return s.State }},
+	"query":            {kind: stringField, string: func(s *Session) string { return s.Query }},
+	"state_duration":   {kind: numberField, number: func(s *Session) float64 { return s.StateDuration }},
+	"application_name": {kind: stringField, string: func(s *Session) string { return s.ApplicationName }},
+	"backend_type":     {kind: stringField, string: func(s *Session) string { return s.BackendType }},
+	"maintenance":      {kind: stringField, string: func(s *Session) string { return s.Maintenance }},
+	"transaction":      {kind: stringField, string: func(s *Session) string { return s.Transaction }},
+	"query_id":         {kind: stringField, string: func(s *Session) string { return strconv.FormatInt(s.QueryID, 10) }},
+}
+
+// ParseExpression parses an expression and returns errors with their position
+func ParseExpression(input string) (Expression, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expression, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != eofToken {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", token.text, token.position)
+	}
+	return expression, nil
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	stringToken
+	numberToken
+	operatorToken
+	leftParenToken
+	rightParenToken
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	position int
+}
+
+// tokenize splits the input into tokens
+func tokenize(input string) (tokens []token, err error) {
+	runes := []rune(input)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: leftParenToken, text: "(", position: start + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: rightParenToken, text: ")", position: start + 1})
+			i++
+		case r == '\'' || r == '"':
+			i++
+			var value strings.Builder
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, token{kind: stringToken, text: value.String(), position: start + 1})
+		case strings.ContainsRune("=!<>~", r):
+			i++
+			for i < len(runes) && strings.ContainsRune("=~", runes[i]) && i-start < 2 {
+				i++
+			}
+			text := string(runes[start:i])
+			switch text {
+			case "=", "!=", "<", "<=", ">", ">=", "~", "!~":
+			default:
+				return nil, fmt.Errorf("unknown operator '%s' at position %d", text, start+1)
+			}
+			tokens = append(tokens, token{kind: operatorToken, text: text, position: start + 1})
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || unicode.IsLetter(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: numberToken, text: string(runes[start:i]), position: start + 1})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == ':') {
+				i++
+			}
+			text := string(runes[start:i])
+			if strings.ToLower(text) == "in" {
+				tokens = append(tokens, token{kind: operatorToken, text: "in", position: start + 1})
+			} else {
+				tokens = append(tokens, token{kind: identToken, text: text, position: start + 1})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected '%c' at position %d", r, start+1)
+		}
+	}
+	tokens = append(tokens, token{kind: eofToken, text: "end of expression", position: len(runes) + 1})
+	return tokens, nil
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	index  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.index]
+}
+
+func (p *parser) next() token {
+	token := p.tokens[p.index]
+	if token.kind != eofToken {
+		p.index++
+	}
+	return token
+}
+
+// keyword returns true and consumes the next token when it's the keyword
+func (p *parser) keyword(keyword string) bool {
+	if token := p.peek(); token.kind == identToken && strings.ToLower(token.text) == keyword {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expression() (Expression, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpression{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Expression, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpression{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) factor() (Expression, error) {
+	if p.keyword("not") {
+		expression, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{expression}, nil
+	}
+	if p.peek().kind == leftParenToken {
+		p.next()
+		expression, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if token := p.next(); token.kind != rightParenToken {
+			return nil, fmt.Errorf("expected ')' at position %d, got '%s'", token.position, token.text)
+		}
+		return expression, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (Expression, error) {
+	name := p.next()
+	if name.kind != identToken {
+		return nil, fmt.Errorf("expected field at position %d, got '%s'", name.position, name.text)
+	}
+	f, ok := fieldDefinition(name.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown field '%s' at position %d", name.text, name.position)
+	}
+	operator := p.next()
+	if operator.kind != operatorToken {
+		return nil, fmt.Errorf("expected operator at position %d, got '%s'", operator.position, operator.text)
+	}
+	value := p.next()
+
+	switch operator.text {
+	case "~", "!~":
+		if f.kind != stringField || value.kind != stringToken {
+			return nil, fmt.Errorf("operator '%s' at position %d requires a text field and a quoted regex", operator.text, operator.position)
+		}
+		regex, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex at position %d: %v", value.position, err)
+		}
+		return regexExpression{field: f, regex: regex, negate: operator.text == "!~"}, nil
+	case "in":
+		if f.kind != stringField || value.kind != stringToken {
+			return nil, fmt.Errorf("operator 'in' at position %d requires a text field and a quoted CIDR", operator.position)
+		}
+		_, network, err := net.ParseCIDR(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR at position %d: %v", value.position, err)
+		}
+		// Client is the address followed by the port, IPv6 addresses are not bracketed
+		if name.text == "client" {
+			f = fields["client_addr"]
+		}
+		return cidrExpression{field: f, network: network}, nil
+	}
+
+	if f.kind == numberField {
+		if value.kind != numberToken {
+			return nil, fmt.Errorf("field '%s' at position %d requires a number, got '%s'", name.text, value.position, value.text)
+		}
+		number, err := ParseSettingDuration(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number at position %d: %v", value.position, err)
+		}
+		return numberExpression{field: f, operator: operator.text, value: number}, nil
+	}
+	if value.kind != stringToken {
+		return nil, fmt.Errorf("field '%s' at position %d requires a quoted string, got '%s'", name.text, value.position, value.text)
+	}
+	return stringExpression{field: f, operator: operator.text, value: value.text}, nil
+}
+
+// fieldDefinition returns the definition of a field by name
+// Labels are fields named "label:" followed by the label key
+func fieldDefinition(name string) (field, bool) {
+	if strings.HasPrefix(name, "label:") && len(name) > len("label:") {
+		key := strings.TrimPrefix(name, "label:")
+		return field{kind: stringField, string: func(s *Session) string { return s.Labels[key] }}, true
+	}
+	f, ok := fields[strings.ToLower(name)]
+	return f, ok
+}
+
+// Expressions
+
+type orExpression struct {
+	left, right Expression
+}
+
+func (e orExpression) Eval(s *Session) bool {
+	return e.left.Eval(s) || e.right.Eval(s)
+}
+
+type andExpression struct {
+	left, right Expression
+}
+
+func (e andExpression) Eval(s *Session) bool {
+	return e.left.Eval(s) && e.right.Eval(s)
+}
+
+type notExpression struct {
+	expression Expression
+}
+
+func (e notExpression) Eval(s *Session) bool {
+	return !e.expression.Eval(s)
+}
+
+type stringExpression struct {
+	field    field
+	operator string
+	value    string
+}
+
+func (e stringExpression) Eval(s *Session) bool {
+	return compare(strings.Compare(e.field.string(s), e.value), e.operator)
+}
+
+type numberExpression struct {
+	field    field
+	operator string
+	value    float64
+}
+
+func (e numberExpression) Eval(s *Session) bool {
+	value := e.field.number(s)
+	switch {
+	case value < e.value:
+		return compare(-1, e.operator)
+	case value > e.value:
+		return compare(1, e.operator)
+	}
+	return compare(0, e.operator)
+}
+
+type regexExpression struct {
+	field  field
+	regex  *regexp.Regexp
+	negate bool
+}
+
+func (e regexExpression) Eval(s *Session) bool {
+	return e.regex.MatchString(e.field.string(s)) != e.negate
+}
+
+type cidrExpression struct {
+	field   field
+	network *net.IPNet
+}
+
+func (e cidrExpression) Eval(s *Session) bool {
+	ip := net.ParseIP(e.field.string(s))
+	return ip != nil && e.network.Contains(ip)
+}
+
+// compare returns the result of an operator given the result of a comparison
+func compare(result int, operator string) bool {
+	switch operator {
+	case "=":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	}
+	return false
+}
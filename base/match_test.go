@@ -0,0 +1,126 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	session := &Session{
+		Pid:             42,
+		User:            "etl",
+		Db:              "warehouse",
+		Client:          "10.1.2.3:5432",
+		State:           "active",
+		Query:           "SELECT 1",
+		StateDuration:   600,
+		ApplicationName: "report_daily",
+		Transaction:     ReadOnlyTransaction,
+		Labels:          map[string]string{"team": "payments"},
+		QueryID:         -3216494862158427374,
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+	}{
+		{"Equal", "user = 'etl'", true},
+		{"Not equal", "user != 'etl'", false},
+		{"Double quotes", `db = "warehouse"`, true},
+		{"Number", "pid >= 42", true},
+		{"Duration", "state_duration > 5min", true},
+		{"Duration in milliseconds", "state_duration < 1000ms", false},
+		{"Regex", "application_name ~ '^report_'", true},
+		{"Negated regex", "query !~ '(?i)^select'", false},
+		{"CIDR", "client in '10.0.0.0/8'", true},
+		{"CIDR outside", "client_addr in '192.168.0.0/16'", false},
+		{"CIDR without port", "client_addr in '10.1.2.3/32'", true},
+		{"And", "user = 'etl' and db = 'test'", false},
+		{"Or", "user = 'etl' or db = 'test'", true},
+		{"Not", "not user = 'etl'", false},
+		{"Precedence", "db = 'test' and user = 'test' or pid = 42", true},
+		{"Parentheses", "db = 'test' and (user = 'test' or pid = 42)", false},
+		{"Transaction", "transaction = 'read-only'", true},
+		{"Query identifier", "query_id = '-3216494862158427374'", true},
+		{"Label", "label:team = 'payments'", true},
+		{"Missing label", "label:env = ''", true},
+		{"Case insensitive keywords", "NOT db = 'test' AND user = 'etl'", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expression, err := ParseExpression(tc.expression)
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			got := expression.Eval(session)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionIPv6(t *testing.T) {
+	session := &Session{Client: "2001:db8::1:5432"}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+	}{
+		{"Client", "client in '2001:db8::1/128'", true},
+		{"Client outside", "client in '2001:db9::/32'", false},
+		{"Client address", "client_addr in '2001:db8::1/128'", true},
+		{"Port not part of address", "client in '2001:db8::1:5432/128'", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expression, err := ParseExpression(tc.expression)
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			got := expression.Eval(session)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{"Empty", ""},
+		{"Unknown field", "owner = 'etl'"},
+		{"Label without key", "label: = 'etl'"},
+		{"Missing operator", "user 'etl'"},
+		{"Unknown operator", "user == 'etl'"},
+		{"Unterminated string", "user = 'etl"},
+		{"Number on text field", "user = 42"},
+		{"Text on number field", "pid = '42'"},
+		{"Invalid regex", "user ~ '('"},
+		{"Invalid CIDR", "client in '10.0.0.0/33'"},
+		{"Invalid unit", "state_duration > 5years"},
+		{"Missing parenthesis", "(user = 'etl'"},
+		{"Trailing tokens", "user = 'etl' db = 'test'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseExpression(tc.expression)
+			if err == nil {
+				t.Errorf("got no error; want error")
+			} else {
+				t.Logf("got error %v; want error", err)
+			}
+		})
+	}
+}
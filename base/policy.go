@@ -0,0 +1,165 @@
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Actions of policies on active sessions
+const (
+	// TerminateAction terminates sessions
+	TerminateAction = "terminate"
+	// CancelAction cancels queries of sessions
+	CancelAction = "cancel"
+)
+
+// Policy overrides timeouts and action for sessions matching its criteria
+// Empty criteria match any session, nil timeouts and empty action are not overridden
+type Policy struct {
+	Name                   string   `yaml:"name"`
+	Users                  []string `yaml:"users"`
+	UsersRegex             string   `yaml:"users-regex"`
+	UsersRegexCompiled     *regexp.Regexp
+	Databases              []string `yaml:"databases"`
+	DatabasesRegex         string   `yaml:"databases-regex"`
+	DatabasesRegexCompiled *regexp.Regexp
+	ActiveTimeout          *float64          `yaml:"active-timeout"`
+	IdleTimeout            *float64          `yaml:"idle-timeout"`
+	Labels                 map[string]string `yaml:"labels"`
+	Action                 string            `yaml:"action"`
+	MatchExpression        string            `yaml:"match"`
+	MatchCompiled          Expression
+	QueryIDs               []int64 `yaml:"query-ids"`
+	QueryIDsQuery          string  `yaml:"query-ids-query"`
+	ImportedQueryIDs       map[int64]bool
+}
+
+// Compile validates the policy and compiles its regexes
+func (p *Policy) Compile() (err error) {
+	if p.Name == "" {
+		return fmt.Errorf("policy name is required")
+	}
+	if p.Action != "" && p.Action != TerminateAction && p.Action != CancelAction {
+		return fmt.Errorf("policy %s: action must be '%s' or '%s'", p.Name, TerminateAction, CancelAction)
+	}
+	if (p.ActiveTimeout != nil && *p.ActiveTimeout < 0) || (p.IdleTimeout != nil && *p.IdleTimeout < 0) {
+		return fmt.Errorf("policy %s: timeouts must be positive", p.Name)
+	}
+	p.UsersRegexCompiled = nil
+	if p.UsersRegex != "" {
+		if p.UsersRegexCompiled, err = regexp.Compile(p.UsersRegex); err != nil {
+			return fmt.Errorf("policy %s: %v", p.Name, err)
+		}
+	}
+	p.DatabasesRegexCompiled = nil
+	if p.DatabasesRegex != "" {
+		if p.DatabasesRegexCompiled, err = regexp.Compile(p.DatabasesRegex); err != nil {
+			return fmt.Errorf("policy %s: %v", p.Name, err)
+		}
+	}
+	p.MatchCompiled = nil
+	if p.MatchExpression != "" {
+		if p.MatchCompiled, err = ParseExpression(p.MatchExpression); err != nil {
+			return fmt.Errorf("policy %s: match: %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Match returns true when the session matches all criteria of the policy
+func (p *Policy) Match(session *Session) bool {
+	if p.Users != nil && !InSlice(session.User, p.Users) {
+		return false
+	}
+	if p.UsersRegexCompiled != nil && !p.UsersRegexCompiled.MatchString(session.User) {
+		return false
+	}
+	if p.Databases != nil && !InSlice(session.Db, p.Databases) {
+		return false
+	}
+	if p.DatabasesRegexCompiled != nil && !p.DatabasesRegexCompiled.MatchString(session.Db) {
+		return false
+	}
+	if p.Labels != nil && !MatchLabels(p.Labels, session.Labels) {
+		return false
+	}
+	if p.MatchCompiled != nil && !p.MatchCompiled.Eval(session) {
+		return false
+	}
+	if (p.QueryIDs != nil || p.QueryIDsQuery != "") && !p.matchQueryID(session.QueryID) {
+		return false
+	}
+	return true
+}
+
+// matchQueryID returns true when the query identifier is listed or imported by the policy
+// Sessions without query identifier never match
+func (p *Policy) matchQueryID(queryID int64) bool {
+	if queryID == 0 {
+		return false
+	}
+	if p.ImportedQueryIDs[queryID] {
+		return true
+	}
+	for _, id := range p.QueryIDs {
+		if id == queryID {
+			return true
+		}
+	}
+	return false
+}
+
+// queryIDsTimeout is the statement timeout of queries importing query identifiers
+const queryIDsTimeout = 10 * time.Second
+
+// ImportQueryIDs executes the query of each policy importing query identifiers, like from
+// pg_stat_statements, and replaces identifiers previously imported
+// Identifiers previously imported are kept for policies with a failing query, or none are
+// imported when the query has never succeeded
+func ImportQueryIDs(db *Db, policies []*Policy) (err error) {
+	for _, policy := range policies {
+		if policy.QueryIDsQuery == "" {
+			continue
+		}
+		ids, e := db.QueryIDs(policy.QueryIDsQuery, queryIDsTimeout)
+		if e != nil {
+			err = fmt.Errorf("policy %s: query-ids-query: %v", policy.Name, e)
+			if policy.ImportedQueryIDs == nil {
+				policy.ImportedQueryIDs = make(map[int64]bool)
+			}
+			continue
+		}
+		imported := make(map[int64]bool)
+		for _, id := range ids {
+			imported[id] = true
+		}
+		policy.ImportedQueryIDs = imported
+	}
+	return err
+}
+
+// CompilePolicies validates a list of policies and compiles their regexes
+func CompilePolicies(policies []*Policy) error {
+	names := make(map[string]bool)
+	for _, policy := range policies {
+		if err := policy.Compile(); err != nil {
+			return err
+		}
+		if names[policy.Name] {
+			return fmt.Errorf("policy %s: duplicate name", policy.Name)
+		}
+		names[policy.Name] = true
+	}
+	return nil
+}
+
+// MatchPolicy returns the first policy matching the session or nil
+func MatchPolicy(policies []*Policy, session *Session) *Policy {
+	for _, policy := range policies {
+		if policy.Match(session) {
+			return policy
+		}
+	}
+	return nil
+}
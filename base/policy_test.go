@@ -0,0 +1,82 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestPolicyCompile(t *testing.T) {
+	negative := -1.0
+
+	tests := []struct {
+		name    string
+		policy  *Policy
+		wantErr bool
+	}{
+		{"Valid policy", &Policy{Name: "test", UsersRegex: "^test", Action: CancelAction}, false},
+		{"Missing name", &Policy{}, true},
+		{"Invalid action", &Policy{Name: "test", Action: "kill"}, true},
+		{"Invalid regex", &Policy{Name: "test", DatabasesRegex: "("}, true},
+		{"Negative timeout", &Policy{Name: "test", IdleTimeout: &negative}, true},
+		{"Invalid match", &Policy{Name: "test", MatchExpression: "user ="}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Compile()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v; want error %t", err, tc.wantErr)
+			} else {
+				t.Logf("got error %v; want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompilePoliciesDuplicates(t *testing.T) {
+	policies := []*Policy{{Name: "test"}, {Name: "test"}}
+	if err := CompilePolicies(policies); err == nil {
+		t.Errorf("got no error; want error")
+	}
+}
+
+func TestMatchPolicy(t *testing.T) {
+	policies := []*Policy{
+		{Name: "etl", Users: []string{"etl"}, DatabasesRegex: "^warehouse"},
+		{Name: "analysts", UsersRegex: "^analyst_"},
+		{Name: "vpn", MatchExpression: "client in '10.8.0.0/16'"},
+		{Name: "payments", Labels: map[string]string{"team": "payments"}},
+		{Name: "orm", QueryIDs: []int64{-42}},
+		{Name: "imported", QueryIDsQuery: "select queryid from pg_stat_statements", ImportedQueryIDs: map[int64]bool{42: true}},
+		{Name: "default"},
+	}
+	if err := CompilePolicies(policies); err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+
+	tests := []struct {
+		name    string
+		session *Session
+		want    string
+	}{
+		{"Users and databases", &Session{User: "etl", Db: "warehouse_1"}, "etl"},
+		{"Users without database", &Session{User: "etl", Db: "test"}, "default"},
+		{"Users regex", &Session{User: "analyst_1", Db: "warehouse_1"}, "analysts"},
+		{"Match", &Session{User: "test", Db: "test", Client: "10.8.1.1:5432"}, "vpn"},
+		{"Labels", &Session{User: "test", Db: "test", Labels: map[string]string{"team": "payments"}}, "payments"},
+		{"Query identifiers", &Session{User: "test", Db: "test", QueryID: -42}, "orm"},
+		{"Imported query identifiers", &Session{User: "test", Db: "test", QueryID: 42}, "imported"},
+		{"Unknown query identifier", &Session{User: "test", Db: "test", QueryID: 1}, "default"},
+		{"Fallback", &Session{User: "test", Db: "test"}, "default"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := MatchPolicy(policies, tc.session)
+			if got == nil || got.Name != tc.want {
+				t.Errorf("got %+v; want %s", got, tc.want)
+			} else {
+				t.Logf("got %s; want %s", got.Name, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package base
+
+import (
+	"fmt"
+)
+
+// Walsender represents a replication session with its lag
+type Walsender struct {
+	Session  *Session
+	LagBytes int64
+	LagTime  float64
+}
+
+// Slot represents a replication slot
+type Slot struct {
+	Name          string
+	Type          string
+	Plugin        string
+	Db            string
+	Active        bool
+	ActivePid     int64
+	RetainedBytes int64
+}
+
+// Session returns a Slot as a Session to be sent to notifiers
+func (s *Slot) Session() *Session {
+	state := "inactive"
+	if s.Active {
+		state = "active"
+	}
+	return &Session{
+		Pid:    s.ActivePid,
+		Db:     s.Db,
+		State:  state,
+		Event:  SlotEvent,
+		Reason: fmt.Sprintf("slot=%s type=%s plugin=%s retained_bytes=%d", s.Name, s.Type, s.Plugin, s.RetainedBytes),
+	}
+}
@@ -0,0 +1,116 @@
+package base
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is the number of clock ticks per second used by /proc/<pid>/stat on Linux
+// It is USER_HZ, also returned by sysconf(_SC_CLK_TCK), which is 100 on x86, ARM and most
+// architectures. It is assumed instead of calling sysconf to avoid cgo.
+const clockTicks = 100
+
+// Resources represents operating system resources used by a backend process
+// RSS is the anonymous resident memory, private to the process, in bytes
+type Resources struct {
+	RSS        int64
+	CPU        float64
+	ReadBytes  int64
+	WriteBytes int64
+}
+
+// IsPostgres returns true when the process is a PostgreSQL process, meaning the process
+// is local to the host running pgterminate
+func IsPostgres(procDir string, pid int64) bool {
+	comm, err := ioutil.ReadFile(filepath.Join(procDir, strconv.FormatInt(pid, 10), "comm"))
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(comm)) == "postgres"
+}
+
+// ReadResources reads memory and CPU time used by a process from the proc filesystem
+func ReadResources(procDir string, pid int64) (*Resources, error) {
+	dir := filepath.Join(procDir, strconv.FormatInt(pid, 10))
+	resources := &Resources{}
+
+	// Anonymous resident memory from status in kB, which is private to the backend unlike
+	// VmRSS also counting pages of shared buffers the backend has touched
+	status, err := readFields(filepath.Join(dir, "status"), ":")
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := status["RssAnon"]; ok {
+		kb, err := strconv.ParseInt(strings.TrimSuffix(value, " kB"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse RssAnon of process %d: %v", pid, err)
+		}
+		resources.RSS = kb * 1024
+	}
+
+	// User and system CPU time from stat in clock ticks, after the command name which can
+	// contain spaces and parentheses
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	end := strings.LastIndex(string(stat), ")")
+	if end == -1 {
+		return nil, fmt.Errorf("cannot parse stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 13 {
+		return nil, fmt.Errorf("cannot parse stat of process %d", pid)
+	}
+	for _, field := range fields[11:13] {
+		ticks, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse CPU time of process %d: %v", pid, err)
+		}
+		resources.CPU += float64(ticks) / clockTicks
+	}
+
+	return resources, nil
+}
+
+// ReadIO reads bytes read from and written to storage by a process from the proc
+// filesystem
+// The io file is only readable by the owner of the process, so it is read apart from other
+// resources
+func ReadIO(procDir string, pid int64) (readBytes int64, writeBytes int64, err error) {
+	io, err := readFields(filepath.Join(procDir, strconv.FormatInt(pid, 10), "io"), ":")
+	if err != nil {
+		return 0, 0, err
+	}
+	if readBytes, err = strconv.ParseInt(io["read_bytes"], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse read_bytes of process %d: %v", pid, err)
+	}
+	if writeBytes, err = strconv.ParseInt(io["write_bytes"], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse write_bytes of process %d: %v", pid, err)
+	}
+	return readBytes, writeBytes, nil
+}
+
+// readFields reads "key<separator> value" lines of a file
+func readFields(file string, separator string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), separator, 2)
+		if len(parts) == 2 {
+			fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return fields, scanner.Err()
+}
@@ -0,0 +1,99 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeProc writes comm, status, stat and io files of a fake process into procDir
+func writeProc(t *testing.T, procDir string, pid string, comm string, rssKb string, utime string, stime string, readBytes string) {
+	dir := filepath.Join(procDir, pid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"comm":   comm + "\n",
+		"status": "Name:\t" + comm + "\nVmRSS:\t  999999 kB\nRssAnon:\t  " + rssKb + " kB\n",
+		"stat":   pid + " (" + comm + " (x)) S 1 1 1 0 -1 4194560 100 0 0 0 " + utime + " " + stime + " 0 0 20 0 1 0\n",
+		"io":     "rchar: 1\nwchar: 1\nread_bytes: " + readBytes + "\nwrite_bytes: 4096\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadResources(t *testing.T) {
+	procDir := t.TempDir()
+	writeProc(t, procDir, "42", "postgres", "2048", "150", "50", "8192")
+
+	// The io file is read apart and not required
+	if err := os.Remove(filepath.Join(procDir, "42", "io")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadResources(procDir, 42)
+	if err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+	want := &Resources{RSS: 2048 * 1024, CPU: 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	} else {
+		t.Logf("got %+v; want %+v", got, want)
+	}
+
+	if _, err = ReadResources(procDir, 43); err == nil {
+		t.Errorf("got no error for missing process; want error")
+	}
+}
+
+func TestReadIO(t *testing.T) {
+	procDir := t.TempDir()
+	writeProc(t, procDir, "42", "postgres", "2048", "150", "50", "8192")
+
+	readBytes, writeBytes, err := ReadIO(procDir, 42)
+	if err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+	if readBytes != 8192 || writeBytes != 4096 {
+		t.Errorf("got %d and %d; want 8192 and 4096", readBytes, writeBytes)
+	} else {
+		t.Logf("got %d and %d; want 8192 and 4096", readBytes, writeBytes)
+	}
+
+	if _, _, err = ReadIO(procDir, 43); err == nil {
+		t.Errorf("got no error for missing process; want error")
+	}
+}
+
+func TestIsPostgres(t *testing.T) {
+	procDir := t.TempDir()
+	writeProc(t, procDir, "1", "postgres", "0", "0", "0", "0")
+	writeProc(t, procDir, "2", "bash", "0", "0", "0", "0")
+
+	tests := []struct {
+		name string
+		pid  int64
+		want bool
+	}{
+		{"PostgreSQL process", 1, true},
+		{"Other process", 2, false},
+		{"Missing process", 3, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := IsPostgres(procDir, tc.pid)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
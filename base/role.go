@@ -0,0 +1,20 @@
+package base
+
+// Role represents a PostgreSQL role with its attributes and the roles it's a member of,
+// directly or inherited
+type Role struct {
+	Name        string
+	Superuser   bool
+	Replication bool
+	MemberOf    []string
+}
+
+// Disallowed represents a session of a role not allowed to login anymore or connected to a
+// database not allowing connections anymore
+type Disallowed struct {
+	Session          *Session
+	CanLogin         bool
+	Expired          bool
+	ValidUntil       string
+	AllowConnections bool
+}
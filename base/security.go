@@ -0,0 +1,67 @@
+package base
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// tlsVersions orders TLS protocol versions reported by pg_stat_ssl
+var tlsVersions = map[string]int{
+	"TLSv1":   1,
+	"TLSv1.1": 2,
+	"TLSv1.2": 3,
+	"TLSv1.3": 4,
+}
+
+// Encryption represents the encryption of a session
+// Local sessions are connected over a Unix socket and are never encrypted
+type Encryption struct {
+	Session      *Session
+	Local        bool
+	SSL          bool
+	Version      string
+	Cipher       string
+	ClientDN     string
+	GSSEncrypted bool
+}
+
+// SecurityRequirement represents the encryption sessions must use
+type SecurityRequirement struct {
+	SSLRequired      bool
+	MinProtocol      string
+	Ciphers          []string
+	ClientDNRegex    *regexp.Regexp
+	GSSAPIEncryption bool
+}
+
+// ValidTLSVersion returns true when the version is a known TLS protocol version
+func ValidTLSVersion(version string) bool {
+	_, ok := tlsVersions[version]
+	return ok
+}
+
+// Enabled returns true when at least one requirement is configured
+func (r *SecurityRequirement) Enabled() bool {
+	return r.SSLRequired || r.MinProtocol != "" || r.Ciphers != nil || r.ClientDNRegex != nil
+}
+
+// Violation returns why the encryption doesn't meet the requirement or an empty string
+// Sessions encrypted with GSSAPI meet the requirement when GSSAPI encryption is allowed
+func (r *SecurityRequirement) Violation(e *Encryption) string {
+	if !e.SSL {
+		if r.GSSAPIEncryption && e.GSSEncrypted {
+			return ""
+		}
+		return "ssl=off"
+	}
+	if r.MinProtocol != "" && tlsVersions[e.Version] < tlsVersions[r.MinProtocol] {
+		return fmt.Sprintf("protocol=%s", e.Version)
+	}
+	if r.Ciphers != nil && !InSlice(e.Cipher, r.Ciphers) {
+		return fmt.Sprintf("cipher=%s", e.Cipher)
+	}
+	if r.ClientDNRegex != nil && !r.ClientDNRegex.MatchString(e.ClientDN) {
+		return fmt.Sprintf("client_dn=%s", e.ClientDN)
+	}
+	return ""
+}
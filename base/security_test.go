@@ -0,0 +1,40 @@
+package base
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSecurityRequirementViolation(t *testing.T) {
+	secure := &Encryption{SSL: true, Version: "TLSv1.3", Cipher: "TLS_AES_256_GCM_SHA384", ClientDN: "/CN=app"}
+
+	tests := []struct {
+		name        string
+		requirement *SecurityRequirement
+		encryption  *Encryption
+		want        string
+	}{
+		{"SSL required", &SecurityRequirement{SSLRequired: true}, &Encryption{}, "ssl=off"},
+		{"SSL on", &SecurityRequirement{SSLRequired: true}, secure, ""},
+		{"GSSAPI not allowed", &SecurityRequirement{SSLRequired: true}, &Encryption{GSSEncrypted: true}, "ssl=off"},
+		{"GSSAPI allowed", &SecurityRequirement{SSLRequired: true, GSSAPIEncryption: true}, &Encryption{GSSEncrypted: true}, ""},
+		{"Protocol implies SSL", &SecurityRequirement{MinProtocol: "TLSv1.2"}, &Encryption{}, "ssl=off"},
+		{"Old protocol", &SecurityRequirement{MinProtocol: "TLSv1.2"}, &Encryption{SSL: true, Version: "TLSv1.1"}, "protocol=TLSv1.1"},
+		{"Recent protocol", &SecurityRequirement{MinProtocol: "TLSv1.2"}, secure, ""},
+		{"Cipher not allowed", &SecurityRequirement{Ciphers: []string{"TLS_AES_128_GCM_SHA256"}}, secure, "cipher=TLS_AES_256_GCM_SHA384"},
+		{"Cipher allowed", &SecurityRequirement{Ciphers: []string{"TLS_AES_256_GCM_SHA384"}}, secure, ""},
+		{"Client DN not allowed", &SecurityRequirement{ClientDNRegex: regexp.MustCompile("^/CN=admin$")}, secure, "client_dn=/CN=app"},
+		{"Client DN allowed", &SecurityRequirement{ClientDNRegex: regexp.MustCompile("^/CN=app$")}, secure, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.requirement.Violation(tc.encryption)
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			} else {
+				t.Logf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -3,8 +3,32 @@ package base
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
+// Transaction classes of sessions
+const (
+	// WriteTransaction for transactions with an assigned transaction id
+	WriteTransaction = "write"
+	// ReadOnlyTransaction for transactions without assigned transaction id
+	ReadOnlyTransaction = "read-only"
+)
+
+// states maps states named on the command line, like "idle-in-transaction", to states of
+// sessions
+var states = map[string]string{
+	"active":                      "active",
+	"idle":                        "idle",
+	"idle-in-transaction":         "idle in transaction",
+	"idle-in-transaction-aborted": "idle in transaction (aborted)",
+}
+
+// ParseState returns the state of sessions named on the command line
+func ParseState(name string) (string, bool) {
+	state, ok := states[name]
+	return state, ok
+}
+
 // Session represents a PostgreSQL backend
 type Session struct {
 	Pid             int64
@@ -15,6 +39,19 @@ type Session struct {
 	Query           string
 	StateDuration   float64
 	ApplicationName string
+	Event           string
+	Reason          string
+	Maintenance     string
+	BackendType     string
+	Annotation      *Annotation
+	Policy          *Policy
+	Action          string
+	Transaction     string
+	Labels          map[string]string
+	Resources       *Resources
+	QueryID         int64
+	Fingerprint     string
+	BackendStart    time.Time
 }
 
 // NewSession instanciates a Session
@@ -33,6 +70,10 @@ func NewSession(pid int64, user string, db string, client string, state string,
 
 // Format returns a Session as a string by replacing placeholders with their respective value
 func (s *Session) Format(format string) string {
+	var annotation string
+	if s.Annotation != nil {
+		annotation = s.Annotation.Text
+	}
 	definitions := map[string]string{
 		"%p": fmt.Sprintf("%d", s.Pid),
 		"%u": s.User,
@@ -42,9 +83,26 @@ func (s *Session) Format(format string) string {
 		"%m": fmt.Sprintf("%f", s.StateDuration),
 		"%q": s.Query,
 		"%a": s.ApplicationName,
+		"%e": s.Event,
+		"%R": s.Reason,
+		"%A": annotation,
+		"%t": s.Transaction,
+		"%f": s.Fingerprint,
 	}
 
-	output := format
+	var rss, cpu, readBytes, writeBytes string
+	if s.Resources != nil {
+		rss = fmt.Sprintf("%d", s.Resources.RSS)
+		cpu = fmt.Sprintf("%f", s.Resources.CPU)
+		readBytes = fmt.Sprintf("%d", s.Resources.ReadBytes)
+		writeBytes = fmt.Sprintf("%d", s.Resources.WriteBytes)
+	}
+	resources := strings.NewReplacer("%{rss}", rss, "%{cpu}", cpu, "%{read_bytes}", readBytes, "%{write_bytes}", writeBytes)
+
+	output := labelPlaceholderRegex.ReplaceAllStringFunc(format, func(placeholder string) string {
+		return s.Labels[labelPlaceholderRegex.FindStringSubmatch(placeholder)[1]]
+	})
+	output = resources.Replace(output)
 
 	for placeholder, value := range definitions {
 		output = strings.Replace(output, placeholder, value, -1)
@@ -61,6 +119,19 @@ func (s *Session) IsIdle() bool {
 	return false
 }
 
+// ClientAddr returns the address of the client without its port
+func (s *Session) ClientAddr() string {
+	if i := strings.LastIndex(s.Client, ":"); i != -1 {
+		return s.Client[:i]
+	}
+	return s.Client
+}
+
+// IsIdleInTransaction returns true when a session is doing nothing inside a transaction
+func (s *Session) IsIdleInTransaction() bool {
+	return s.State == "idle in transaction" || s.State == "idle in transaction (aborted)"
+}
+
 // Equal returns true when two sessions share the same process id
 func (s *Session) Equal(session *Session) bool {
 	if s.Pid == 0 {
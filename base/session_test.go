@@ -137,3 +137,28 @@ func TestSessionInSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOk bool
+	}{
+		{"Active", "active", "active", true},
+		{"Idle in transaction", "idle-in-transaction", "idle in transaction", true},
+		{"Aborted", "idle-in-transaction-aborted", "idle in transaction (aborted)", true},
+		{"Unknown", "idle in transaction", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseState(tc.input)
+			if got != tc.want || ok != tc.wantOk {
+				t.Errorf("got %s, %t; want %s, %t", got, ok, tc.want, tc.wantOk)
+			} else {
+				t.Logf("got %s, %t; want %s, %t", got, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
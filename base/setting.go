@@ -0,0 +1,111 @@
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SettingPrefix is the prefix of PostgreSQL settings read by pgterminate
+	SettingPrefix = "pgterminate."
+	// ActiveTimeoutSetting overrides active-timeout
+	ActiveTimeoutSetting = "active_timeout"
+	// IdleTimeoutSetting overrides idle-timeout
+	IdleTimeoutSetting = "idle_timeout"
+)
+
+// settingUnits converts PostgreSQL time units to seconds
+var settingUnits = map[string]float64{
+	"us":  0.000001,
+	"ms":  0.001,
+	"s":   1,
+	"min": 60,
+	"h":   3600,
+	"d":   86400,
+}
+
+// settingRegex matches a number followed by an optional time unit
+var settingRegex = regexp.MustCompile(`^\s*([0-9]+(?:\.[0-9]+)?)\s*([a-z]*)\s*$`)
+
+// Setting represents a threshold defined on a role, a database or both
+// An empty Role or Db matches any role or database
+type Setting struct {
+	Db    string
+	Role  string
+	Name  string
+	Value float64
+}
+
+// Settings is a list of settings
+type Settings []*Setting
+
+// ParseSetting creates a Setting from a "pgterminate.name=value" string set on a role
+// and a database
+// Values are durations using PostgreSQL time units, seconds by default
+func ParseSetting(db string, role string, setting string) (*Setting, error) {
+	parts := strings.SplitN(setting, "=", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], SettingPrefix) {
+		return nil, fmt.Errorf("invalid setting '%s'", setting)
+	}
+	name := strings.TrimPrefix(parts[0], SettingPrefix)
+	if name != ActiveTimeoutSetting && name != IdleTimeoutSetting {
+		return nil, fmt.Errorf("unknown setting '%s'", parts[0])
+	}
+	value, err := ParseSettingDuration(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for setting '%s': %v", parts[0], err)
+	}
+	return &Setting{Db: db, Role: role, Name: name, Value: value}, nil
+}
+
+// ParseSettingDuration converts a duration like "30min" to seconds
+func ParseSettingDuration(value string) (float64, error) {
+	matches := settingRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("'%s' is not a duration", value)
+	}
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := matches[2]
+	if unit == "" {
+		unit = "s"
+	}
+	factor, ok := settingUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit '%s', valid units are us, ms, s, min, h and d", unit)
+	}
+	return number * factor, nil
+}
+
+// Lookup returns the value of a setting for a role and a database
+// Like PostgreSQL, settings defined on both role and database take precedence over
+// settings defined on the role, then settings defined on the database
+func (s Settings) Lookup(role string, db string, name string) (value float64, found bool) {
+	priority := 0
+	for _, setting := range s {
+		if setting.Name != name {
+			continue
+		}
+		var p int
+		switch {
+		case setting.Role == role && setting.Db == db:
+			p = 3
+		case setting.Role == role && setting.Db == "":
+			p = 2
+		case setting.Role == "" && setting.Db == db:
+			p = 1
+		default:
+			continue
+		}
+		if p > priority {
+			priority = p
+			value = setting.Value
+			found = true
+		}
+	}
+	return value, found
+}
@@ -0,0 +1,116 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestParseSettingDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{"No unit", "30", 30, false},
+		{"Seconds", "30s", 30, false},
+		{"Minutes", "30min", 1800, false},
+		{"Hours", "1h", 3600, false},
+		{"Days", "1d", 86400, false},
+		{"Milliseconds", "1500ms", 1.5, false},
+		{"Decimal", "1.5h", 5400, false},
+		{"Spaces", " 10 min ", 600, false},
+		{"Unknown unit", "10m", 0, true},
+		{"Negative", "-10", 0, true},
+		{"Not a number", "forever", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSettingDuration(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got no error; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %f; want %f", got, tc.want)
+			} else {
+				t.Logf("got %f; want %f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		want    Setting
+		wantErr bool
+	}{
+		{"Active timeout", "pgterminate.active_timeout=30min", Setting{Name: ActiveTimeoutSetting, Value: 1800}, false},
+		{"Idle timeout", "pgterminate.idle_timeout=10", Setting{Name: IdleTimeoutSetting, Value: 10}, false},
+		{"Unknown setting", "pgterminate.timeout=10", Setting{}, true},
+		{"Other prefix", "work_mem=10", Setting{}, true},
+		{"Invalid value", "pgterminate.idle_timeout=never", Setting{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSetting("", "", tc.setting)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got no error; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			if *got != tc.want {
+				t.Errorf("got %+v; want %+v", *got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSettingsLookup(t *testing.T) {
+	settings := Settings{
+		{Db: "test", Name: ActiveTimeoutSetting, Value: 1},
+		{Role: "analyst", Name: ActiveTimeoutSetting, Value: 2},
+		{Db: "test", Role: "analyst", Name: ActiveTimeoutSetting, Value: 3},
+		{Role: "analyst", Name: IdleTimeoutSetting, Value: 4},
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		db        string
+		setting   string
+		want      float64
+		wantFound bool
+	}{
+		{"Database", "postgres", "test", ActiveTimeoutSetting, 1, true},
+		{"Role", "analyst", "postgres", ActiveTimeoutSetting, 2, true},
+		{"Role and database", "analyst", "test", ActiveTimeoutSetting, 3, true},
+		{"Other setting", "analyst", "test", IdleTimeoutSetting, 4, true},
+		{"Not found", "postgres", "postgres", ActiveTimeoutSetting, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, found := settings.Lookup(tc.role, tc.db, tc.setting)
+			if got != tc.want || found != tc.wantFound {
+				t.Errorf("got %f, %t; want %f, %t", got, found, tc.want, tc.wantFound)
+			} else {
+				t.Logf("got %f, %t; want %f, %t", got, found, tc.want, tc.wantFound)
+			}
+		})
+	}
+}
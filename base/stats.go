@@ -0,0 +1,159 @@
+package base
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxDurations is the number of durations kept by fingerprint
+const maxDurations = 1000
+
+// FingerprintStats represents durations of runs of a query fingerprint
+// Only the last durations are kept to follow the evolution of queries
+type FingerprintStats struct {
+	Query     string    `json:"query"`
+	Count     int64     `json:"count"`
+	Durations []float64 `json:"durations"`
+	Next      int       `json:"next"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Percentile returns the p percentile of durations using the nearest rank method
+func (f *FingerprintStats) Percentile(p float64) float64 {
+	if len(f.Durations) == 0 {
+		return 0
+	}
+	durations := append([]float64{}, f.Durations...)
+	sort.Float64s(durations)
+	rank := int(math.Ceil(p/100*float64(len(durations)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return durations[rank]
+}
+
+// Stats stores duration statistics by query fingerprint
+type Stats struct {
+	Fingerprints map[string]*FingerprintStats `json:"fingerprints"`
+	mutex        sync.RWMutex
+}
+
+// NewStats creates a Stats object
+func NewStats() *Stats {
+	return &Stats{Fingerprints: make(map[string]*FingerprintStats)}
+}
+
+// Record adds the duration of a run to the statistics of a fingerprint
+func (s *Stats) Record(fingerprint string, query string, duration float64, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats, ok := s.Fingerprints[fingerprint]
+	if !ok {
+		stats = &FingerprintStats{}
+		s.Fingerprints[fingerprint] = stats
+	}
+	stats.Query = query
+	stats.Count++
+	stats.LastSeen = now
+	if len(stats.Durations) < maxDurations {
+		stats.Durations = append(stats.Durations, duration)
+	} else {
+		stats.Durations[stats.Next] = duration
+	}
+	stats.Next = (stats.Next + 1) % maxDurations
+}
+
+// Evict removes fingerprints not seen for more than maxAge, then least recently seen
+// fingerprints to keep at most maxFingerprints
+// A zero maxAge or maxFingerprints disables the corresponding eviction.
+func (s *Stats) Evict(now time.Time, maxAge time.Duration, maxFingerprints int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if maxAge != 0 {
+		for fingerprint, stats := range s.Fingerprints {
+			if now.Sub(stats.LastSeen) > maxAge {
+				delete(s.Fingerprints, fingerprint)
+			}
+		}
+	}
+	if maxFingerprints == 0 || len(s.Fingerprints) <= maxFingerprints {
+		return
+	}
+	fingerprints := make([]string, 0, len(s.Fingerprints))
+	for fingerprint := range s.Fingerprints {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		return s.Fingerprints[fingerprints[i]].LastSeen.Before(s.Fingerprints[fingerprints[j]].LastSeen)
+	})
+	for _, fingerprint := range fingerprints[:len(fingerprints)-maxFingerprints] {
+		delete(s.Fingerprints, fingerprint)
+	}
+}
+
+// Percentile returns the p percentile of durations of a fingerprint and the number of
+// durations it's computed from
+func (s *Stats) Percentile(fingerprint string, p float64) (float64, int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stats, ok := s.Fingerprints[fingerprint]
+	if !ok {
+		return 0, 0
+	}
+	return stats.Percentile(p), len(stats.Durations)
+}
+
+// ReadStats loads statistics from a file
+// Empty statistics are returned when the file doesn't exist
+func ReadStats(file string) (*Stats, error) {
+	stats := NewStats()
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, stats); err != nil {
+		return nil, err
+	}
+	if stats.Fingerprints == nil {
+		stats.Fingerprints = make(map[string]*FingerprintStats)
+	}
+	return stats, nil
+}
+
+// Write saves statistics to a file atomically
+func (s *Stats) Write(file string) error {
+	s.mutex.RLock()
+	content, err := json.Marshal(s)
+	s.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFile(file, content)
+}
+
+// writeFile writes content to a temporary file renamed to the file, so the file is never
+// partially written
+func writeFile(file string, content []byte) error {
+	temporary, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err = temporary.Write(content); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), file)
+}
@@ -0,0 +1,121 @@
+package base
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestStatsPercentile(t *testing.T) {
+	stats := NewStats()
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		stats.Record("a", "select ?", float64(i), now)
+	}
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		percentile  float64
+		want        float64
+		wantSamples int
+	}{
+		{"Median", "a", 50, 50, 100},
+		{"99th percentile", "a", 99, 99, 100},
+		{"Maximum", "a", 100, 100, 100},
+		{"Unknown fingerprint", "b", 99, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, samples := stats.Percentile(tc.fingerprint, tc.percentile)
+			if got != tc.want || samples != tc.wantSamples {
+				t.Errorf("got %f with %d samples; want %f with %d samples", got, samples, tc.want, tc.wantSamples)
+			} else {
+				t.Logf("got %f with %d samples; want %f with %d samples", got, samples, tc.want, tc.wantSamples)
+			}
+		})
+	}
+}
+
+func TestStatsRecordRotation(t *testing.T) {
+	stats := NewStats()
+	now := time.Now()
+	for i := 0; i < maxDurations; i++ {
+		stats.Record("a", "select ?", 100, now)
+	}
+	for i := 0; i < maxDurations; i++ {
+		stats.Record("a", "select ?", 1, now)
+	}
+
+	got, samples := stats.Percentile("a", 100)
+	if got != 1 || samples != maxDurations {
+		t.Errorf("got %f with %d samples; want 1 with %d samples", got, samples, maxDurations)
+	}
+	if count := stats.Fingerprints["a"].Count; count != 2*maxDurations {
+		t.Errorf("got count %d; want %d", count, 2*maxDurations)
+	}
+}
+
+func TestStatsEvict(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		maxAge          time.Duration
+		maxFingerprints int
+		want            []string
+	}{
+		{"Disabled", 0, 0, []string{"new", "old", "recent"}},
+		{"Age", 2 * time.Hour, 0, []string{"new", "recent"}},
+		{"Count", 0, 1, []string{"new"}},
+		{"Age and count", 2 * time.Hour, 1, []string{"new"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := NewStats()
+			stats.Record("old", "select ?", 1, now.Add(-3*time.Hour))
+			stats.Record("recent", "select ?", 1, now.Add(-time.Hour))
+			stats.Record("new", "select ?", 1, now)
+			stats.Evict(now, tc.maxAge, tc.maxFingerprints)
+
+			var got []string
+			for fingerprint := range stats.Fingerprints {
+				got = append(got, fingerprint)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			} else {
+				t.Logf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatsFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "stats.json")
+
+	stats, err := ReadStats(file)
+	if err != nil {
+		t.Fatalf("got error %v; want empty statistics for a missing file", err)
+	}
+	stats.Record("a", "select ?", 3, time.Now())
+	if err = stats.Write(file); err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+
+	stats, err = ReadStats(file)
+	if err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+	got, samples := stats.Percentile("a", 99)
+	if got != 3 || samples != 1 || stats.Fingerprints["a"].Query != "select ?" {
+		t.Errorf("got %f with %d samples; want 3 with 1 sample", got, samples)
+	} else {
+		t.Logf("got %f with %d samples; want 3 with 1 sample", got, samples)
+	}
+}
@@ -0,0 +1,44 @@
+package base
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Throttle represents the connection limit of a role lowered until the end of a cool-down
+type Throttle struct {
+	Previous int       `json:"previous"`
+	Limit    int       `json:"limit"`
+	Until    time.Time `json:"until"`
+}
+
+// ReadThrottles loads throttled roles from a file
+// No throttled role is returned when the file doesn't exist
+func ReadThrottles(file string) (map[string]*Throttle, error) {
+	throttles := make(map[string]*Throttle)
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return throttles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &throttles); err != nil {
+		return nil, err
+	}
+	if throttles == nil {
+		throttles = make(map[string]*Throttle)
+	}
+	return throttles, nil
+}
+
+// WriteThrottles saves throttled roles to a file atomically
+func WriteThrottles(file string, throttles map[string]*Throttle) error {
+	content, err := json.Marshal(throttles)
+	if err != nil {
+		return err
+	}
+	return writeFile(file, content)
+}
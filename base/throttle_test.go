@@ -0,0 +1,31 @@
+package base
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestThrottlesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "throttles.json")
+
+	throttles, err := ReadThrottles(file)
+	if err != nil || len(throttles) != 0 {
+		t.Fatalf("got %d throttles and error %v; want no throttle for a missing file", len(throttles), err)
+	}
+	throttles["app"] = &Throttle{Previous: -1, Limit: 2, Until: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	if err = WriteThrottles(file, throttles); err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+
+	got, err := ReadThrottles(file)
+	if err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+	if !reflect.DeepEqual(got, throttles) {
+		t.Errorf("got %+v; want %+v", got["app"], throttles["app"])
+	} else {
+		t.Logf("got %+v; want %+v", got["app"], throttles["app"])
+	}
+}
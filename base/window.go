@@ -0,0 +1,65 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window represents a daily time window like "01:00-05:00"
+// Start and End are durations since midnight, End can be lower than Start when the window
+// ends the next day
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow creates a Window from a string like "01:00-05:00"
+func ParseWindow(s string) (w *Window, err error) {
+	bounds := strings.Split(strings.TrimSpace(s), "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("invalid window '%s': must be formatted like 01:00-05:00", s)
+	}
+	w = &Window{}
+	w.Start, err = parseTimeOfDay(bounds[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid window '%s': %v", s, err)
+	}
+	w.End, err = parseTimeOfDay(bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid window '%s': %v", s, err)
+	}
+	return w, nil
+}
+
+// parseTimeOfDay returns the duration since midnight of a time like "05:00"
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// length returns the duration of the window
+func (w *Window) length() time.Duration {
+	length := w.End - w.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	return length
+}
+
+// Overrun returns true when started is inside an occurrence of the window and now is
+// after the end of this occurrence
+func (w *Window) Overrun(started time.Time, now time.Time) bool {
+	midnight := time.Date(started.Year(), started.Month(), started.Day(), 0, 0, 0, 0, started.Location())
+	for _, day := range []time.Time{midnight.AddDate(0, 0, -1), midnight} {
+		start := day.Add(w.Start)
+		end := start.Add(w.length())
+		if !started.Before(start) && started.Before(end) {
+			return !now.Before(end)
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package base
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Window
+		wantErr bool
+	}{
+		{"Same day", "01:00-05:30", Window{Start: time.Hour, End: 5*time.Hour + 30*time.Minute}, false},
+		{"Next day", "22:00-02:00", Window{Start: 22 * time.Hour, End: 2 * time.Hour}, false},
+		{"Missing end", "01:00", Window{}, true},
+		{"Invalid time", "01:00-25:00", Window{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseWindow(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got no error; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			if *got != tc.want {
+				t.Errorf("got %+v; want %+v", *got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWindowOverrun(t *testing.T) {
+	at := func(day int, hour int) time.Time {
+		return time.Date(2023, time.January, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		window  string
+		started time.Time
+		now     time.Time
+		want    bool
+	}{
+		{"Running inside window", "01:00-05:00", at(1, 2), at(1, 4), false},
+		{"Running past window end", "01:00-05:00", at(1, 2), at(1, 6), true},
+		{"Started outside window", "01:00-05:00", at(1, 6), at(1, 8), false},
+		{"Running inside window crossing midnight", "22:00-02:00", at(1, 23), at(2, 1), false},
+		{"Running past window crossing midnight", "22:00-02:00", at(1, 23), at(2, 3), true},
+		{"Started after midnight inside window", "22:00-02:00", at(2, 1), at(2, 3), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			window, err := ParseWindow(tc.window)
+			if err != nil {
+				t.Fatalf("got error %v; want no error", err)
+			}
+			got := window.Overrun(tc.started, tc.now)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
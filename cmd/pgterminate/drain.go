@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+	"github.com/jouir/pgterminate/notifier"
+	"github.com/jouir/pgterminate/terminator"
+)
+
+// drain blocks a database and kills its sessions, or allows it again with the undrain
+// command, then exits with a non-zero code on failure
+// Interrupting a drain allows the database again.
+func drain(command string, config *base.Config, options terminator.DrainOptions) {
+	done := make(chan bool)
+	sessions := make(chan *base.Session)
+	ctx := base.NewContext(config, sessions, done)
+	t := terminator.NewTerminator(ctx)
+	n := notifier.NewNotifier(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.Run()
+	}()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range c {
+			log.Debugf("Received %v signal\n", sig)
+			done <- true
+		}
+	}()
+
+	var err error
+	if command == "drain" {
+		err = t.Drain(options)
+	} else {
+		err = t.Undrain(options)
+	}
+	close(sessions)
+	wg.Wait()
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
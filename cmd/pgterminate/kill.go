@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+	"github.com/jouir/pgterminate/notifier"
+	"github.com/jouir/pgterminate/terminator"
+)
+
+// Exit codes of the kill command
+const (
+	killSucceeded   = 0
+	killError       = 1
+	killNoCandidate = 2
+	killAborted     = 3
+	killIncomplete  = 4
+)
+
+// maxQueryDisplayLength is the length of queries printed in the list of candidates
+const maxQueryDisplayLength = 60
+
+// kill prints sessions matching options and filters, asks for confirmation unless yes is
+// true, kills them once and exits with a code reflecting the outcome
+func kill(config *base.Config, options terminator.KillOptions, yes bool) {
+	done := make(chan bool)
+	sessions := make(chan *base.Session)
+	ctx := base.NewContext(config, sessions, done)
+	t := terminator.NewTerminator(ctx)
+	n := notifier.NewNotifier(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.Run()
+	}()
+
+	code := killCandidates(t, options, yes)
+	close(sessions)
+	wg.Wait()
+	os.Exit(code)
+}
+
+// killCandidates kills candidates once confirmed and returns the exit code
+func killCandidates(t *terminator.Terminator, options terminator.KillOptions, yes bool) int {
+	if err := t.Connect(); err != nil {
+		log.Errorf("%v\n", err)
+		return killError
+	}
+	defer t.Disconnect()
+
+	candidates := t.Candidates(options)
+	if len(candidates) == 0 {
+		fmt.Println("No session to kill")
+		return killNoCandidate
+	}
+	printCandidates(candidates)
+
+	var changed []*base.Session
+	if !yes {
+		if !confirm(fmt.Sprintf("Kill %d sessions? [y/N] ", len(candidates))) {
+			fmt.Println("Aborted")
+			return killAborted
+		}
+		// Sessions may have ended or changed while waiting for the confirmation
+		candidates, changed = t.Recheck(candidates, options)
+	}
+
+	failed, err := t.Kill(candidates)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		return killError
+	}
+	fmt.Printf("Killed %d sessions\n", len(candidates)-len(failed))
+	for _, session := range changed {
+		fmt.Printf("Not killing session %d, it has ended or doesn't match anymore\n", session.Pid)
+	}
+	for _, session := range failed {
+		fmt.Printf("Cannot kill session %d, it may have ended in the meantime\n", session.Pid)
+	}
+	if len(changed) > 0 || len(failed) > 0 {
+		return killIncomplete
+	}
+	return killSucceeded
+}
+
+// printCandidates prints sessions to kill as a table
+func printCandidates(sessions []*base.Session) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tUSER\tDATABASE\tCLIENT\tSTATE\tDURATION\tREASON\tQUERY")
+	for _, session := range sessions {
+		query := strings.Join(strings.Fields(session.Query), " ")
+		if len(query) > maxQueryDisplayLength {
+			query = query[:maxQueryDisplayLength] + "..."
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.3f\t%s\t%s\n", session.Pid, session.User, session.Db, session.Client, session.State, session.StateDuration, session.Reason, query)
+	}
+	w.Flush()
+}
+
+// confirm prints a question and returns true when the answer read from the standard input
+// starts with "y"
+func confirm(question string) bool {
+	fmt.Print(question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Print("\n")
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+}
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -30,8 +31,28 @@ var GitCommit string
 var GoVersion string
 
 func main() {
+	// Commands are given before flags
+	var command string
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	switch command {
+	case "stats":
+		stats(os.Args[1:])
+		return
+	case "", "drain", "undrain", "kill":
+	default:
+		log.Fatalf("Unknown command %s\n", command)
+	}
+
 	var err error
 	config := base.NewConfig()
+	var drainOptions terminator.DrainOptions
+	var killOptions terminator.KillOptions
+	var killStates base.StringFlags
+	var killMinDuration string
+	var killYes bool
 
 	quiet := flag.Bool("quiet", false, "Quiet mode")
 	verbose := flag.Bool("verbose", false, "Verbose mode")
@@ -48,7 +69,44 @@ func main() {
 	flag.Float64Var(&config.Interval, "interval", 1, "Time to sleep between iterations in seconds")
 	flag.IntVar(&config.ConnectTimeout, "connect-timeout", 3, "Connection timeout in seconds")
 	flag.Float64Var(&config.IdleTimeout, "idle-timeout", 0, "Time for idle connections to be terminated in seconds")
+	flag.Float64Var(&config.IdleInWriteTransactionTimeout, "idle-in-write-transaction-timeout", 0, "Time for connections idle in a transaction with a transaction id to be terminated in seconds")
+	flag.Float64Var(&config.IdleInReadOnlyTransactionTimeout, "idle-in-read-only-transaction-timeout", 0, "Time for connections idle in a transaction without transaction id to be terminated in seconds")
 	flag.Float64Var(&config.ActiveTimeout, "active-timeout", 0, "Time for active connections to be terminated in seconds")
+	flag.BoolVar(&config.DatabaseSettings, "database-settings", false, "Override timeouts with pgterminate settings defined on roles and databases")
+	flag.Float64Var(&config.SettingsRefreshInterval, "settings-refresh-interval", 60, "Time to refresh settings defined on roles and databases in seconds")
+	flag.BoolVar(&config.DisableAnnotations, "disable-annotations", false, "Ignore pgterminate annotations in queries")
+	flag.Var(&config.AnnotationsDisabledUsers, "annotations-disabled-user", "Ignore pgterminate annotations in queries of this user (can be called multiple times)")
+	flag.Float64Var(&config.AnnotationCeiling, "annotation-ceiling", 0, "Maximum timeout in seconds allowed by pgterminate annotations in queries (default to no limit)")
+	flag.StringVar(&config.PoliciesDatabase, "policies-database", "", "Read policies from a table in this database")
+	flag.StringVar(&config.PoliciesTable, "policies-table", "pgterminate.policies", "Table to read policies from")
+	flag.Float64Var(&config.PoliciesRefreshInterval, "policies-refresh-interval", 60, "Time to refresh policies from the policies table in seconds")
+	flag.BoolVar(&config.SSLRequired, "ssl-required", false, "Terminate sessions connected over the network without SSL")
+	flag.StringVar(&config.SSLMinProtocolVersion, "ssl-min-protocol-version", "", "Terminate sessions using an older SSL protocol version between 'TLSv1', 'TLSv1.1', 'TLSv1.2' or 'TLSv1.3'")
+	flag.Var(&config.SSLCiphers, "ssl-cipher", "Terminate sessions not using this SSL cipher (can be called multiple times)")
+	flag.StringVar(&config.SSLClientDNRegex, "ssl-client-dn-regex", "", "Terminate sessions with a client certificate distinguished name not matching this regexp")
+	flag.BoolVar(&config.GSSAPIEncryptionAllowed, "gssapi-encryption-allowed", false, "Accept sessions encrypted with GSSAPI instead of SSL")
+	flag.BoolVar(&config.SweepDisabledRoles, "sweep-disabled-roles", false, "Terminate sessions of roles with the NOLOGIN attribute or an expired password")
+	flag.BoolVar(&config.SweepDisallowedDatabases, "sweep-disallowed-databases", false, "Terminate sessions connected to databases not allowing connections")
+	flag.BoolVar(&config.HbaCheck, "hba-check", false, "Report sessions rejected by current pg_hba.conf rules")
+	flag.Float64Var(&config.HbaCheckInterval, "hba-check-interval", 60, "Time to check if pg_hba.conf rules have been reloaded in seconds")
+	flag.BoolVar(&config.HbaTerminate, "hba-terminate", false, "Terminate sessions rejected by current pg_hba.conf rules")
+	flag.Int64Var(&config.RSSLimit, "rss-limit", 0, "Private resident memory in bytes for active queries to be cancelled (requires pgterminate on the database host)")
+	flag.Float64Var(&config.CPULimit, "cpu-limit", 0, "CPU time in seconds used by a backend for its active query to be cancelled (requires pgterminate on the database host)")
+	flag.Int64Var(&config.ReadBytesLimit, "read-bytes-limit", 0, "Bytes read from storage by a backend for its active query to be cancelled (requires pgterminate on the database host)")
+	flag.Float64Var(&config.AdaptiveFactor, "adaptive-factor", 0, "Cancel queries running longer than this factor of the 99th percentile of their fingerprint durations")
+	flag.Float64Var(&config.AdaptiveMinTimeout, "adaptive-min-timeout", 60, "Minimum time for queries to be cancelled by the adaptive factor in seconds")
+	flag.Float64Var(&config.AdaptiveMaxTimeout, "adaptive-max-timeout", 0, "Maximum time for queries to be cancelled by the adaptive factor in seconds (default to no limit)")
+	flag.IntVar(&config.AdaptiveMinSamples, "adaptive-min-samples", 20, "Number of runs of a fingerprint required to apply the adaptive factor")
+	flag.StringVar(&config.AdaptiveStatsFile, "adaptive-stats-file", "", "Persist durations of query fingerprints into this file")
+	flag.Float64Var(&config.AdaptiveSaveInterval, "adaptive-save-interval", 60, "Time to save durations of query fingerprints in seconds")
+	flag.Float64Var(&config.AdaptiveMaxAge, "adaptive-max-age", 2592000, "Time to forget query fingerprints not seen anymore in seconds (0 to disable)")
+	flag.IntVar(&config.AdaptiveMaxFingerprints, "adaptive-max-fingerprints", 10000, "Maximum number of query fingerprints kept, least recently seen first forgotten (0 to disable)")
+	flag.IntVar(&config.RepeatOffenderKills, "repeat-offender-kills", 0, "Number of kills of a role, application and client in the window to report them as repeat offender")
+	flag.Float64Var(&config.RepeatOffenderWindow, "repeat-offender-window", 3600, "Time window to count kills of repeat offenders in seconds")
+	flag.Float64Var(&config.RepeatOffenderCooldown, "repeat-offender-cooldown", 3600, "Time for repeat offenders to be throttled in seconds")
+	flag.IntVar(&config.RepeatOffenderConnectionLimit, "repeat-offender-connection-limit", -1, "Lower the connection limit of roles of repeat offenders to this value during the cool-down (default to no change)")
+	flag.StringVar(&config.RepeatOffenderPolicy, "repeat-offender-policy", "", "Apply this policy to sessions of repeat offenders during the cool-down")
+	flag.StringVar(&config.RepeatOffenderStateFile, "repeat-offender-state-file", "", "Persist throttled roles into this file to restore their connection limit after a restart")
 	flag.StringVar(&config.LogDestination, "log-destination", "console", "Log destination between 'console', 'syslog' or 'file'")
 	flag.StringVar(&config.LogFile, "log-file", "", "Write logs to a file")
 	flag.StringVar(&config.LogFormat, "log-format", "pid=%p user=%u db=%d client=%r state=%s state_duration=%m query=%q", "Represent messages using this format")
@@ -63,11 +121,59 @@ func main() {
 	flag.StringVar(&config.IncludeDatabasesRegex, "include-databases-regex", "", "Terminate databases matching this regexp")
 	flag.Var(&config.ExcludeDatabases, "exclude-database", "Ignore this database (can be called multiple times)")
 	flag.StringVar(&config.ExcludeDatabasesRegex, "exclude-databases-regex", "", "Ignore databases matching this regexp")
+	flag.BoolVar(&config.ExcludeSuperusers, "exclude-superusers", false, "Ignore superusers")
+	flag.BoolVar(&config.ExcludeReplicationRoles, "exclude-replication-roles", false, "Ignore roles with replication attribute")
+	flag.Var(&config.IncludeMembersOf, "include-member-of", "Terminate only members of this role, directly or inherited (can be called multiple times)")
+	flag.Float64Var(&config.RolesRefreshInterval, "roles-refresh-interval", 60, "Time to refresh role attributes and memberships in seconds")
+	flag.StringVar(&config.LabelsSeparator, "labels-separator", "", "Parse labels from application names split by this separator, like 'team=payments;env=prod'")
+	flag.StringVar(&config.LabelsAssignment, "labels-assignment", "=", "Separator between keys and values of labels")
+	flag.StringVar(&config.LabelsRegex, "labels-regex", "", "Parse labels from application names with named groups of this regexp")
+	flag.StringVar(&config.MatchExpression, "match", "", "Terminate only sessions matching this expression")
+	flag.StringVar(&config.Hook, "hook", "", "Command deciding what to do with sessions to cancel or terminate")
+	flag.Float64Var(&config.HookTimeout, "hook-timeout", 1, "Time for the hook to answer in seconds")
+	flag.StringVar(&config.HookFailMode, "hook-fail-mode", base.FailOpen, "Handling of sessions the hook didn't answer between 'open' (cancel or terminate) or 'closed' (ignore)")
 	flag.BoolVar(&config.ExcludeListeners, "exclude-listeners", false, "Ignore sessions listening for events")
 	flag.BoolVar(&config.Cancel, "cancel", false, "Cancel sessions instead of terminate")
+	flag.Int64Var(&config.ReplicationLagBytes, "replication-lag-bytes", 0, "Replication lag in bytes for walsenders to be terminated")
+	flag.Float64Var(&config.ReplicationLagTime, "replication-lag-time", 0, "Replication lag in seconds for walsenders to be terminated")
+	flag.Int64Var(&config.SlotRetainedBytes, "slot-retained-bytes", 0, "Report inactive replication slots retaining more WAL bytes than this")
+	flag.BoolVar(&config.DropSlots, "drop-slots", false, "Drop inactive replication slots reported by -slot-retained-bytes")
+	flag.Float64Var(&config.StandbyIdleTimeout, "standby-idle-timeout", 0, "Time for idle connections to be terminated in seconds on standbys (default to -idle-timeout)")
+	flag.Float64Var(&config.StandbyActiveTimeout, "standby-active-timeout", 0, "Time for active connections to be terminated in seconds on standbys (default to -active-timeout)")
+	flag.Float64Var(&config.StandbyReplayLag, "standby-replay-lag", 0, "Replay lag in seconds for the longest running queries to be cancelled on standbys")
+	flag.IntVar(&config.LockQueueSize, "lock-queue-size", 0, "Number of sessions waiting behind a DDL for the lock queue action to be taken")
+	flag.StringVar(&config.LockQueueAction, "lock-queue-action", base.CancelDDL, "Action taken when a lock queue builds up between 'cancel-ddl' or 'terminate-blockers'")
+	flag.BoolVar(&config.LockQueueIdleInTransaction, "lock-queue-idle-in-transaction", false, "Terminate sessions blocking a DDL only when they are idle in transaction")
+	flag.Var(&config.Relations, "relation", "Cancel or terminate sessions locking this relation (can be called multiple times)")
+	flag.StringVar(&config.RelationsRegex, "relations-regex", "", "Cancel or terminate sessions locking relations matching this regexp")
+	flag.Float64Var(&config.AdvisoryLockIdleTimeout, "advisory-lock-idle-timeout", 0, "Time for idle sessions holding advisory locks to be terminated in seconds")
+	flag.Float64Var(&config.AdvisoryLockTimeout, "advisory-lock-timeout", 0, "Time for sessions holding the same advisory lock to be terminated in seconds")
+	flag.Var(&config.AdvisoryLockClassIDs, "advisory-lock-classid", "Only consider advisory locks with classid in this range like '1000-1999' (can be called multiple times)")
+	flag.Var(&config.AdvisoryLockObjIDs, "advisory-lock-objid", "Only consider advisory locks with objid in this range like '1000-1999' (can be called multiple times)")
+	flag.Float64Var(&config.MaintenanceTimeout, "maintenance-timeout", 0, "Time for active maintenance operations to be terminated in seconds (default to never)")
+	flag.Float64Var(&config.BackupTimeout, "backup-timeout", 0, "Time for backups to be reported as hung in seconds (default to never)")
+	flag.BoolVar(&config.AutovacuumBlockingDDL, "autovacuum-blocking-ddl", false, "Cancel autovacuum workers blocking a DDL")
+	flag.IntVar(&config.AutovacuumLockQueueSize, "autovacuum-lock-queue-size", 0, "Number of sessions waiting behind an autovacuum worker for the worker to be cancelled")
+	flag.StringVar(&config.AutovacuumWindow, "autovacuum-window", "", "Cancel autovacuum workers started in this daily window like '01:00-05:00' and running past its end")
+	if command == "drain" || command == "undrain" {
+		flag.StringVar(&drainOptions.MaintenanceDatabase, "maintenance-database", "postgres", "Database to connect to, different from the drained database given by -database")
+	}
+	if command == "drain" {
+		flag.Float64Var(&drainOptions.Grace, "grace", 30, "Time for active sessions to finish before being cancelled or terminated in seconds")
+		flag.Float64Var(&drainOptions.Timeout, "timeout", 60, "Time for sessions to leave after the grace period before the drain fails in seconds")
+	}
+	if command == "kill" {
+		flag.Var(&killStates, "state", "Kill sessions in this state between 'active', 'idle', 'idle-in-transaction' and 'idle-in-transaction-aborted' (can be called multiple times, default to any state)")
+		flag.StringVar(&killMinDuration, "min-duration", "0", "Kill sessions in their state for at least this duration like '30s' or '5min'")
+		flag.BoolVar(&killYes, "yes", false, "Kill sessions without asking for confirmation")
+	}
 	flag.Parse()
+	drainOptions.Database = config.Database
 
 	log.SetLevel(log.WarnLevel)
+	if command != "" {
+		log.SetLevel(log.InfoLevel)
+	}
 	if *debug {
 		log.SetLevel(log.DebugLevel)
 	}
@@ -99,8 +205,36 @@ func main() {
 		base.Panic(err)
 	}
 
-	if config.ActiveTimeout == 0 && config.IdleTimeout == 0 {
-		log.Fatal("Parameter -active-timeout or -idle-timeout required")
+	if command == "" && !config.HasRules() {
+		log.Fatal("At least one rule is required, like -active-timeout or -idle-timeout (see -help)")
+	}
+
+	if config.LockQueueAction != base.CancelDDL && config.LockQueueAction != base.TerminateBlockers {
+		log.Fatal("Lock queue action must be 'cancel-ddl' or 'terminate-blockers'")
+	}
+
+	if config.HookFailMode != base.FailOpen && config.HookFailMode != base.FailClosed {
+		log.Fatal("Hook fail mode must be 'open' or 'closed'")
+	}
+
+	if config.SSLMinProtocolVersion != "" && !base.ValidTLSVersion(config.SSLMinProtocolVersion) {
+		log.Fatal("SSL minimum protocol version must be 'TLSv1', 'TLSv1.1', 'TLSv1.2' or 'TLSv1.3'")
+	}
+
+	if config.AdaptiveFactor < 0 || (config.AdaptiveFactor != 0 && config.AdaptiveFactor < 1) {
+		log.Fatal("Adaptive factor must be greater than or equal to 1")
+	}
+
+	if config.AdaptiveMaxAge < 0 || config.AdaptiveMaxFingerprints < 0 {
+		log.Fatal("Adaptive maximum age and maximum fingerprints must be greater than or equal to 0")
+	}
+
+	if config.RepeatOffenderKills == 0 && (config.RepeatOffenderConnectionLimit >= 0 || config.RepeatOffenderPolicy != "") {
+		log.Fatal("Parameters -repeat-offender-connection-limit and -repeat-offender-policy require -repeat-offender-kills")
+	}
+
+	if config.DropSlots && config.SlotRetainedBytes == 0 {
+		log.Fatal("Parameter -drop-slots requires -slot-retained-bytes")
 	}
 
 	if config.LogDestination != "console" && config.LogDestination != "file" && config.LogDestination != "syslog" {
@@ -117,8 +251,43 @@ func main() {
 
 	err = config.CompileRegexes()
 	base.Panic(err)
+	err = config.CompileRanges()
+	base.Panic(err)
+	err = config.CompilePolicies()
+	base.Panic(err)
 	config.CompileFilters()
 
+	if config.PoliciesDatabase != "" {
+		matched, err := regexp.MatchString(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`, config.PoliciesTable)
+		base.Panic(err)
+		if !matched {
+			log.Fatal("Policies table must be a table name optionally qualified by a schema")
+		}
+	}
+
+	if command == "kill" {
+		for _, name := range killStates {
+			state, ok := base.ParseState(name)
+			if !ok {
+				log.Fatal("State must be 'active', 'idle', 'idle-in-transaction' or 'idle-in-transaction-aborted'")
+			}
+			killOptions.States = append(killOptions.States, state)
+		}
+		if killOptions.MinDuration, err = base.ParseSettingDuration(killMinDuration); err != nil {
+			log.Fatalf("Cannot parse minimum duration: %v\n", err)
+		}
+		kill(config, killOptions, killYes)
+		return
+	}
+
+	if command == "drain" || command == "undrain" {
+		if drainOptions.Database == "" || drainOptions.Database == drainOptions.MaintenanceDatabase {
+			log.Fatal("Parameter -database must name the drained database, different from -maintenance-database")
+		}
+		drain(command, config, drainOptions)
+		return
+	}
+
 	if config.PidFile != "" {
 		writePid(config.PidFile)
 		defer removePid(config.PidFile)
@@ -131,7 +300,7 @@ func main() {
 	terminator := terminator.NewTerminator(ctx)
 	notifier := notifier.NewNotifier(ctx)
 
-	handleSignals(ctx, notifier)
+	handleSignals(ctx, terminator, notifier)
 
 	// Run managers asynchronously and wait for all of them to end
 	var wg sync.WaitGroup
@@ -148,7 +317,7 @@ func main() {
 }
 
 // handleSignals handles operating system signals
-func handleSignals(ctx *base.Context, n notifier.Notifier) {
+func handleSignals(ctx *base.Context, t *terminator.Terminator, n notifier.Notifier) {
 	// When interrupt or terminated, terminate managers, close channel and terminate program
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT)
@@ -161,13 +330,14 @@ func handleSignals(ctx *base.Context, n notifier.Notifier) {
 		}
 	}()
 
-	// When hangup, reload notifier
+	// When hangup, reload terminator and notifier
 	h := make(chan os.Signal, 1)
 	signal.Notify(h, syscall.SIGHUP)
 	go func() {
 		for sig := range h {
 			log.Debugf("Received %v signal\n", sig)
 			ctx.Config.Reload()
+			t.Reload()
 			n.Reload()
 		}
 	}()
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// stats prints durations of query fingerprints learned for adaptive thresholds
+func stats(args []string) {
+	config := base.NewConfig()
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	flags.StringVar(&config.File, "config", "", "Configuration file")
+	file := flags.String("adaptive-stats-file", "", "Statistics file (default to the file of the configuration)")
+	fingerprint := flags.String("fingerprint", "", "Print statistics of this fingerprint only")
+	limit := flags.Int("limit", 0, "Print this number of fingerprints with the most runs (default to all)")
+	flags.Parse(args)
+
+	if config.File != "" {
+		if err := config.Read(config.File); err != nil {
+			log.Fatalf("Cannot parse configuration file: %v", err)
+		}
+	}
+	if *file == "" {
+		*file = config.AdaptiveStatsFile
+	}
+	if *file == "" {
+		log.Fatal("Statistics file required")
+	}
+
+	s, err := base.ReadStats(*file)
+	if err != nil {
+		log.Fatalf("Cannot read statistics file: %v", err)
+	}
+
+	var fingerprints []string
+	for key := range s.Fingerprints {
+		if *fingerprint == "" || key == *fingerprint {
+			fingerprints = append(fingerprints, key)
+		}
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		return s.Fingerprints[fingerprints[i]].Count > s.Fingerprints[fingerprints[j]].Count
+	})
+	if *limit > 0 && len(fingerprints) > *limit {
+		fingerprints = fingerprints[:*limit]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FINGERPRINT\tRUNS\tSAMPLES\tP50\tP99\tLAST SEEN\tQUERY")
+	for _, key := range fingerprints {
+		f := s.Fingerprints[key]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.3f\t%.3f\t%s\t%s\n", key, f.Count, len(f.Durations), f.Percentile(50), f.Percentile(99), f.LastSeen.Format("2006-01-02 15:04:05"), f.Query)
+	}
+	w.Flush()
+}
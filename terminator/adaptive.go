@@ -0,0 +1,160 @@
+package terminator
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// adaptivePercentile is the percentile of durations multiplied by the adaptive factor
+const adaptivePercentile = 99
+
+// queryRun tracks a query running in a backend since its last state change
+type queryRun struct {
+	fingerprint string
+	query       string
+	stateChange time.Time
+	duration    float64
+	cancelled   bool
+}
+
+// loadStats reads durations of query fingerprints from the statistics file
+func (t *Terminator) loadStats() {
+	if !t.config.Learning() {
+		return
+	}
+	t.stats = base.NewStats()
+	if t.config.AdaptiveStatsFile == "" {
+		return
+	}
+	stats, err := base.ReadStats(t.config.AdaptiveStatsFile)
+	if err != nil {
+		log.Warnf("Cannot read statistics file %s: %v\n", t.config.AdaptiveStatsFile, err)
+		return
+	}
+	t.stats = stats
+	t.statsSaved = time.Now()
+}
+
+// saveStats writes durations of query fingerprints to the statistics file
+func (t *Terminator) saveStats() {
+	if t.stats == nil || t.config.AdaptiveStatsFile == "" {
+		return
+	}
+	if err := t.stats.Write(t.config.AdaptiveStatsFile); err != nil {
+		log.Warnf("Cannot write statistics file %s: %v\n", t.config.AdaptiveStatsFile, err)
+		return
+	}
+	log.Debugf("Statistics saved to %s\n", t.config.AdaptiveStatsFile)
+}
+
+// adaptive learns durations of queries by fingerprint and cancels queries running longer
+// than the adaptive factor of the 99th percentile of their fingerprint
+// Queries are cancelled unless the policy hook or the session policy decides otherwise.
+// Fingerprints not seen for too long and least recently seen fingerprints over the limit are
+// forgotten.
+func (t *Terminator) adaptive(sessions []*base.Session) {
+	if t.stats == nil {
+		return
+	}
+	now := time.Now()
+	t.trackRuns(sessions, now)
+	t.stats.Evict(now, time.Duration(t.config.AdaptiveMaxAge*1000)*time.Millisecond, t.config.AdaptiveMaxFingerprints)
+
+	if t.config.AdaptiveFactor != 0 {
+		targets := t.decide(labelSessions(t.protect(t.filter(slowSessions(sessions, t.stats, t.config.AdaptiveFactor, t.config.AdaptiveMinTimeout, t.config.AdaptiveMaxTimeout, t.config.AdaptiveMinSamples)))), base.AdaptiveEvent)
+		for _, session := range targets {
+			if session.Action == "" && (session.Policy == nil || session.Policy.Action == "") {
+				session.Action = base.CancelDecision
+			}
+			if run, ok := t.queryRuns[session.Pid]; ok {
+				run.cancelled = true
+			}
+		}
+		t.kill(targets)
+		t.notify(targets, base.AdaptiveEvent)
+	}
+
+	if now.Sub(t.statsSaved) > time.Duration(t.config.AdaptiveSaveInterval*1000)*time.Millisecond {
+		t.saveStats()
+		t.statsSaved = now
+	}
+}
+
+// trackRuns sets the fingerprint of active sessions and records the duration of finished
+// runs
+// A run is finished when the backend has left, is not active anymore or has changed state
+// since the previous iteration. The last observed duration is recorded, which is lower than
+// the real duration by at most one interval. Cancelled runs are not recorded to avoid
+// learning from their truncated durations.
+func (t *Terminator) trackRuns(sessions []*base.Session, now time.Time) {
+	seen := make(map[int64]bool)
+	for _, session := range sessions {
+		if session.State != "active" || session.Query == "" {
+			continue
+		}
+		fingerprint, query := base.Fingerprint(session.QueryID, session.Query)
+		session.Fingerprint = fingerprint
+		seen[session.Pid] = true
+
+		stateChange := now.Add(-time.Duration(session.StateDuration*1000) * time.Millisecond)
+		run, ok := t.queryRuns[session.Pid]
+		if ok && (run.fingerprint != fingerprint || stateChange.Sub(run.stateChange) > stateChangeTolerance) {
+			t.finishRun(run, now)
+			ok = false
+		}
+		if !ok {
+			run = &queryRun{fingerprint: fingerprint, query: query, stateChange: stateChange}
+			t.queryRuns[session.Pid] = run
+		}
+		run.duration = session.StateDuration
+	}
+	for pid, run := range t.queryRuns {
+		if !seen[pid] {
+			t.finishRun(run, now)
+			delete(t.queryRuns, pid)
+		}
+	}
+}
+
+// finishRun records the duration of a run unless it has been cancelled
+func (t *Terminator) finishRun(run *queryRun, now time.Time) {
+	if !run.cancelled {
+		t.stats.Record(run.fingerprint, run.query, run.duration, now)
+	}
+}
+
+// adaptiveThreshold returns the duration in seconds above which a query is cancelled given
+// the percentile of its fingerprint, bounded by minimum and maximum durations
+// A zero maximum means no upper bound
+func adaptiveThreshold(percentile float64, factor float64, min float64, max float64) float64 {
+	threshold := math.Max(percentile*factor, min)
+	if max != 0 {
+		threshold = math.Min(threshold, max)
+	}
+	return threshold
+}
+
+// slowSessions returns active sessions running longer than the adaptive threshold of their
+// fingerprint with the fingerprint, the percentile and the threshold as reason
+// Fingerprints with less than minSamples durations are ignored
+func slowSessions(sessions []*base.Session, stats *base.Stats, factor float64, min float64, max float64, minSamples int) (result []*base.Session) {
+	for _, session := range sessions {
+		if session.State != "active" || session.Fingerprint == "" {
+			continue
+		}
+		percentile, samples := stats.Percentile(session.Fingerprint, adaptivePercentile)
+		if samples == 0 || samples < minSamples {
+			continue
+		}
+		threshold := adaptiveThreshold(percentile, factor, min, max)
+		if session.StateDuration > threshold {
+			session.Reason = fmt.Sprintf("fingerprint=%s p%d=%f threshold=%f", session.Fingerprint, adaptivePercentile, percentile, threshold)
+			result = append(result, session)
+		}
+	}
+	return result
+}
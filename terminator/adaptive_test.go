@@ -0,0 +1,111 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestAdaptiveThreshold(t *testing.T) {
+	tests := []struct {
+		name       string
+		percentile float64
+		factor     float64
+		min        float64
+		max        float64
+		want       float64
+	}{
+		{"Factor", 10, 5, 1, 0, 50},
+		{"Minimum", 1, 5, 60, 0, 60},
+		{"Maximum", 1000, 5, 60, 3600, 3600},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := adaptiveThreshold(tc.percentile, tc.factor, tc.min, tc.max)
+			if got != tc.want {
+				t.Errorf("got %f; want %f", got, tc.want)
+			} else {
+				t.Logf("got %f; want %f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSlowSessions(t *testing.T) {
+	stats := base.NewStats()
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		stats.Record("known", "select ?", 10, now)
+		if i < 5 {
+			stats.Record("rare", "select ?", 10, now)
+		}
+	}
+	sessions := []*base.Session{
+		{User: "slow", State: "active", Fingerprint: "known", StateDuration: 120},
+		{User: "usual", State: "active", Fingerprint: "known", StateDuration: 30},
+		{User: "rare", State: "active", Fingerprint: "rare", StateDuration: 120},
+		{User: "unknown", State: "active", Fingerprint: "unknown", StateDuration: 120},
+		{User: "idle", State: "idle", Fingerprint: "known", StateDuration: 120},
+	}
+
+	tests := []struct {
+		name       string
+		factor     float64
+		min        float64
+		minSamples int
+		want       []string
+	}{
+		{"Known fingerprints", 5, 1, 20, []string{"slow"}},
+		{"Rare fingerprints", 5, 1, 5, []string{"slow", "rare"}},
+		{"Minimum timeout", 5, 300, 5, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(slowSessions(sessions, stats, tc.factor, tc.min, 0, tc.minSamples))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrackRuns(t *testing.T) {
+	terminator := &Terminator{
+		stats:     base.NewStats(),
+		queryRuns: make(map[int64]*queryRun),
+	}
+	now := time.Now()
+	query := "select * from t where id = 1"
+
+	steps := []struct {
+		name     string
+		elapsed  time.Duration
+		sessions []*base.Session
+		want     []float64
+	}{
+		{"Started", 0, []*base.Session{{Pid: 1, State: "active", Query: query, StateDuration: 1}}, nil},
+		{"Running", time.Second, []*base.Session{{Pid: 1, State: "active", Query: query, StateDuration: 2}}, nil},
+		{"New query", 2 * time.Second, []*base.Session{{Pid: 1, State: "active", Query: query, StateDuration: 0.5}}, []float64{2}},
+		{"Idle", 3 * time.Second, []*base.Session{{Pid: 1, State: "idle", Query: query, StateDuration: 0.5}}, []float64{2, 0.5}},
+	}
+
+	for _, step := range steps {
+		terminator.trackRuns(step.sessions, now.Add(step.elapsed))
+		fingerprint, _ := base.Fingerprint(0, query)
+		var got []float64
+		if stats, ok := terminator.stats.Fingerprints[fingerprint]; ok {
+			got = stats.Durations
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got %+v; want %+v", step.name, got, step.want)
+		} else {
+			t.Logf("%s: got %+v; want %+v", step.name, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package terminator
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+// advisory terminates sessions holding advisory locks while idle or for too long
+func (t *Terminator) advisory(sessions []*base.Session) {
+	if t.config.AdvisoryLockIdleTimeout == 0 && t.config.AdvisoryLockTimeout == 0 {
+		return
+	}
+	locks := matchingAdvisoryLocks(t.db.AdvisoryLocks(), t.config.AdvisoryLockClassIDsRanges, t.config.AdvisoryLockObjIDsRanges)
+	t.trackAdvisoryLocks(locks, time.Now())
+	targets := t.protect(t.filter(advisorySessions(sessions, locks, t.config.AdvisoryLockIdleTimeout, t.config.AdvisoryLockTimeout)))
+	t.db.TerminateSessions(targets)
+	t.notify(targets, base.AdvisoryLockEvent)
+}
+
+// trackAdvisoryLocks computes for how long locks have been held
+// PostgreSQL doesn't expose when a lock has been acquired so the duration is computed from
+// the first time the lock has been seen. Released locks are forgotten.
+func (t *Terminator) trackAdvisoryLocks(locks []*base.AdvisoryLock, now time.Time) {
+	held := make(map[string]bool)
+	for _, lock := range locks {
+		key := lock.Key()
+		held[key] = true
+		if _, ok := t.advisoryLocksSeen[key]; !ok {
+			t.advisoryLocksSeen[key] = now
+		}
+		lock.Held = now.Sub(t.advisoryLocksSeen[key]).Seconds()
+	}
+	for key := range t.advisoryLocksSeen {
+		if !held[key] {
+			delete(t.advisoryLocksSeen, key)
+		}
+	}
+}
+
+// matchingAdvisoryLocks returns locks with classid and objid included in ranges
+func matchingAdvisoryLocks(locks []*base.AdvisoryLock, classids []base.Range, objids []base.Range) (result []*base.AdvisoryLock) {
+	for _, lock := range locks {
+		if base.InRanges(lock.ClassID, classids) && base.InRanges(lock.ObjID, objids) {
+			result = append(result, lock)
+		}
+	}
+	return result
+}
+
+// advisorySessions returns sessions holding advisory locks idle for more than idleTimeout
+// or holding a lock for more than timeout with lock keys as reason
+// A zero timeout is ignored
+func advisorySessions(sessions []*base.Session, locks []*base.AdvisoryLock, idleTimeout float64, timeout float64) (result []*base.Session) {
+	for _, session := range sessions {
+		var keys []string
+		expired := false
+		for _, lock := range locks {
+			if lock.Pid == session.Pid {
+				keys = append(keys, lock.String())
+				if timeout != 0 && lock.Held > timeout {
+					expired = true
+				}
+			}
+		}
+		if keys == nil {
+			continue
+		}
+		if expired || (idleTimeout != 0 && session.IsIdle() && session.StateDuration > idleTimeout) {
+			session.Reason = "advisory_locks=" + strings.Join(keys, ",")
+			result = append(result, session)
+		}
+	}
+	return result
+}
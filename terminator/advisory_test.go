@@ -0,0 +1,127 @@
+package terminator
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestMatchingAdvisoryLocks(t *testing.T) {
+
+	locks := []*base.AdvisoryLock{
+		{Pid: 1, ClassID: 0, ObjID: 42},
+		{Pid: 2, ClassID: 1000, ObjID: 1},
+		{Pid: 3, ClassID: 1500, ObjID: 2},
+	}
+
+	tests := []struct {
+		name     string
+		classids []base.Range
+		objids   []base.Range
+		want     []int64
+	}{
+		{"No range", nil, nil, []int64{1, 2, 3}},
+		{"Classid range", []base.Range{{Min: 1000, Max: 1999}}, nil, []int64{2, 3}},
+		{"Objid range", nil, []base.Range{{Min: 42, Max: 42}}, []int64{1}},
+		{"Classid and objid ranges", []base.Range{{Min: 1000, Max: 1999}}, []base.Range{{Min: 2, Max: 10}}, []int64{3}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int64
+			for _, lock := range matchingAdvisoryLocks(locks, tc.classids, tc.objids) {
+				got = append(got, lock.Pid)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdvisorySessions(t *testing.T) {
+
+	sessions := []*base.Session{
+		{Pid: 1, User: "test", State: "idle", StateDuration: 600},
+		{Pid: 2, User: "test_1", State: "active", StateDuration: 600},
+		{Pid: 3, User: "test_2", State: "idle", StateDuration: 600},
+	}
+	locks := []*base.AdvisoryLock{
+		{Pid: 1, ClassID: 1000, ObjID: 1, Held: 10},
+		{Pid: 2, ClassID: 1000, ObjID: 2, Held: 3600},
+	}
+
+	tests := []struct {
+		name        string
+		idleTimeout float64
+		timeout     float64
+		want        []string
+	}{
+		{"Idle timeout", 300, 0, []string{"test"}},
+		{"Held timeout", 0, 1800, []string{"test_1"}},
+		{"Idle and held timeouts", 300, 1800, []string{"test", "test_1"}},
+		{"Timeouts not reached", 900, 7200, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(advisorySessions(sessions, locks, tc.idleTimeout, tc.timeout))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrackAdvisoryLocks(t *testing.T) {
+	terminator := &Terminator{advisoryLocksSeen: make(map[string]time.Time)}
+	now := time.Now()
+
+	first := []*base.AdvisoryLock{{Pid: 1, ClassID: 1000, ObjID: 1}}
+	terminator.trackAdvisoryLocks(first, now)
+
+	second := []*base.AdvisoryLock{{Pid: 1, ClassID: 1000, ObjID: 1}, {Pid: 2, ClassID: 1000, ObjID: 2}}
+	terminator.trackAdvisoryLocks(second, now.Add(time.Minute))
+	if second[0].Held != 60 || second[1].Held != 0 {
+		t.Errorf("got %f, %f; want 60, 0", second[0].Held, second[1].Held)
+	}
+
+	terminator.trackAdvisoryLocks(nil, now.Add(2*time.Minute))
+	if len(terminator.advisoryLocksSeen) != 0 {
+		t.Errorf("got %d tracked locks; want 0", len(terminator.advisoryLocksSeen))
+	}
+}
+
+func TestAdvisoryProtected(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    bool
+	}{
+		{"Idle client is terminated", &base.Session{Pid: 1, State: "idle", StateDuration: 10, ApplicationName: "psql"}, true},
+		{"Idle backup is protected", &base.Session{Pid: 1, State: "idle", StateDuration: 10, ApplicationName: "pg_dump"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator, fake := newFakeTerminator(t, &base.Config{AdvisoryLockIdleTimeout: 1},
+				fakeResponse{match: "l.locktype = 'advisory'", rows: [][]driver.Value{{int64(1), "app", int64(0), int64(42), int64(1)}}},
+				fakeResponse{match: "pg_terminate_backend"},
+			)
+			terminator.advisory([]*base.Session{tc.session})
+			got := fake.signaled()
+			if got != tc.want {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
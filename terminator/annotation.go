@@ -0,0 +1,55 @@
+package terminator
+
+import (
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// annotate parses annotations at the start of queries unless annotations are disabled
+// globally or for the user
+// Invalid annotations are ignored
+func (t *Terminator) annotate(sessions []*base.Session) {
+	if t.config.DisableAnnotations {
+		return
+	}
+	for _, session := range sessions {
+		if base.InSlice(session.User, t.config.AnnotationsDisabledUsers) {
+			continue
+		}
+		annotation, err := base.ParseAnnotation(session.Query)
+		if err != nil {
+			log.Debugf("Ignoring annotation of session %d: %v\n", session.Pid, err)
+			continue
+		}
+		session.Annotation = annotation
+	}
+}
+
+// annotationCeiling returns the maximum timeout allowed by annotations for a user
+// A zero ceiling means no limit
+func (t *Terminator) annotationCeiling(user string) float64 {
+	if ceiling, ok := t.config.AnnotationCeilings[user]; ok {
+		return ceiling
+	}
+	return t.config.AnnotationCeiling
+}
+
+// annotationTimeout returns the timeout requested by an annotation capped by the ceiling
+// of the user, or the timeout when the session doesn't override it
+// Exempt sessions get the ceiling as timeout, or are protected when there's no ceiling
+func (t *Terminator) annotationTimeout(session *base.Session, requested float64, timeout float64) float64 {
+	if session.Annotation == nil {
+		return timeout
+	}
+	ceiling := t.annotationCeiling(session.User)
+	if session.Annotation.Exempt && ceiling != 0 {
+		return ceiling
+	}
+	if requested == 0 {
+		return timeout
+	}
+	if ceiling != 0 && requested > ceiling {
+		return ceiling
+	}
+	return requested
+}
@@ -0,0 +1,88 @@
+package terminator
+
+import (
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestAnnotationTimeouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    *base.Config
+		session   *base.Session
+		want      float64
+		protected bool
+	}{
+		{
+			"No annotation",
+			&base.Config{ActiveTimeout: 60},
+			&base.Session{User: "test", Query: "select 1"},
+			60,
+			false,
+		},
+		{
+			"Active timeout annotation",
+			&base.Config{ActiveTimeout: 60},
+			&base.Session{User: "test", Query: "/* pgterminate: active-timeout=1h */ select 1"},
+			3600,
+			false,
+		},
+		{
+			"Active timeout annotation over global ceiling",
+			&base.Config{ActiveTimeout: 60, AnnotationCeiling: 600},
+			&base.Session{User: "test", Query: "/* pgterminate: active-timeout=1h */ select 1"},
+			600,
+			false,
+		},
+		{
+			"Active timeout annotation over role ceiling",
+			&base.Config{ActiveTimeout: 60, AnnotationCeiling: 600, AnnotationCeilings: map[string]float64{"test": 1800}},
+			&base.Session{User: "test", Query: "/* pgterminate: active-timeout=1h */ select 1"},
+			1800,
+			false,
+		},
+		{
+			"Exempt without ceiling",
+			&base.Config{ActiveTimeout: 60},
+			&base.Session{User: "test", Query: "/* pgterminate: exempt */ select 1"},
+			60,
+			true,
+		},
+		{
+			"Exempt with ceiling",
+			&base.Config{ActiveTimeout: 60, AnnotationCeiling: 600},
+			&base.Session{User: "test", Query: "/* pgterminate: exempt */ select 1"},
+			600,
+			false,
+		},
+		{
+			"Annotations disabled",
+			&base.Config{ActiveTimeout: 60, DisableAnnotations: true},
+			&base.Session{User: "test", Query: "/* pgterminate: active-timeout=1h */ select 1"},
+			60,
+			false,
+		},
+		{
+			"Annotations disabled for user",
+			&base.Config{ActiveTimeout: 60, AnnotationsDisabledUsers: []string{"test"}},
+			&base.Session{User: "test", Query: "/* pgterminate: exempt */ select 1"},
+			60,
+			false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: tc.config}
+			terminator.annotate([]*base.Session{tc.session})
+			got := terminator.activeTimeout(tc.session)
+			protected := terminator.protection(tc.session) != ""
+			if got != tc.want || protected != tc.protected {
+				t.Errorf("got %f, %t; want %f, %t", got, protected, tc.want, tc.protected)
+			} else {
+				t.Logf("got %f, %t; want %f, %t", got, protected, tc.want, tc.protected)
+			}
+		})
+	}
+}
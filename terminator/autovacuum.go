@@ -0,0 +1,78 @@
+package terminator
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// Causes for cancelling autovacuum workers
+const (
+	autovacuumBlockingDDL = "blocking-ddl"
+	autovacuumLockQueue   = "lock-queue"
+	autovacuumWindow      = "window"
+)
+
+// autovacuum cancels regular autovacuum workers blocking DDL, with a lock queue behind them
+// or running past the end of the maintenance window
+func (t *Terminator) autovacuum() {
+	if !t.config.AutovacuumBlockingDDL && t.config.AutovacuumLockQueueSize == 0 && t.config.AutovacuumWindowCompiled == nil {
+		return
+	}
+	now := time.Now()
+	var targets []*base.Session
+	for _, worker := range t.db.AutovacuumWorkers() {
+		cause := autovacuumCause(worker, t.config.AutovacuumBlockingDDL, t.config.AutovacuumLockQueueSize, t.config.AutovacuumWindowCompiled, now)
+		if cause == "" || t.filterDatabases([]*base.Session{worker.Session}) == nil {
+			continue
+		}
+		t.resolveRelation(worker)
+		worker.Session.Reason = fmt.Sprintf("relation=%s phase=%s waiters=%d cause=%s", worker.Relation, worker.Phase, worker.Waiters, cause)
+		targets = append(targets, worker.Session)
+	}
+	t.db.CancelSessions(targets)
+	t.notify(targets, base.AutovacuumEvent)
+}
+
+// autovacuumCause returns why an autovacuum worker must be cancelled or an empty string
+// Anti-wraparound workers are never cancelled
+func autovacuumCause(worker *base.AutovacuumWorker, blockingDDL bool, lockQueueSize int, window *base.Window, now time.Time) string {
+	if worker.Wraparound {
+		log.Debugf("Autovacuum worker %d is protected: anti-wraparound\n", worker.Session.Pid)
+		return ""
+	}
+	switch {
+	case blockingDDL && worker.BlockingDDL:
+		return autovacuumBlockingDDL
+	case lockQueueSize != 0 && worker.Waiters >= lockQueueSize:
+		return autovacuumLockQueue
+	case window != nil && window.Overrun(worker.Started, now):
+		return autovacuumWindow
+	}
+	return ""
+}
+
+// resolveRelation replaces the relation oid by its name when the worker runs on another database
+// The oid is kept when the database can't be reached
+func (t *Terminator) resolveRelation(worker *base.AutovacuumWorker) {
+	if worker.Relid == 0 || worker.Relation != strconv.FormatInt(worker.Relid, 10) {
+		return
+	}
+	db, err := t.database(worker.Session.Db)
+	if err != nil {
+		log.Errorf("Cannot connect to database %s to resolve relation %d: %v\n", worker.Session.Db, worker.Relid, err)
+		return
+	}
+	if db == nil {
+		return
+	}
+	name, err := db.RelationName(worker.Relid)
+	if err != nil {
+		log.Errorf("Cannot resolve relation %d in database %s: %v\n", worker.Relid, worker.Session.Db, err)
+		return
+	}
+	worker.Relation = name
+}
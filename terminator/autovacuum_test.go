@@ -0,0 +1,71 @@
+package terminator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestAutovacuumCause(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 6, 0, 0, 0, time.UTC)
+	window := &base.Window{Start: time.Hour, End: 5 * time.Hour}
+
+	tests := []struct {
+		name          string
+		worker        *base.AutovacuumWorker
+		blockingDDL   bool
+		lockQueueSize int
+		window        *base.Window
+		want          string
+	}{
+		{
+			"No policy",
+			&base.AutovacuumWorker{BlockingDDL: true, Waiters: 10},
+			false, 0, nil,
+			"",
+		},
+		{
+			"Blocking DDL",
+			&base.AutovacuumWorker{BlockingDDL: true},
+			true, 0, nil,
+			autovacuumBlockingDDL,
+		},
+		{
+			"Lock queue",
+			&base.AutovacuumWorker{Waiters: 10},
+			false, 5, nil,
+			autovacuumLockQueue,
+		},
+		{
+			"Lock queue too small",
+			&base.AutovacuumWorker{Waiters: 2},
+			false, 5, nil,
+			"",
+		},
+		{
+			"Running past window",
+			&base.AutovacuumWorker{Started: now.Add(-3 * time.Hour)},
+			false, 0, window,
+			autovacuumWindow,
+		},
+		{
+			"Anti-wraparound",
+			&base.AutovacuumWorker{Wraparound: true, BlockingDDL: true, Waiters: 10, Started: now.Add(-3 * time.Hour)},
+			true, 5, window,
+			"",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.worker.Session = &base.Session{}
+			got := autovacuumCause(tc.worker, tc.blockingDDL, tc.lockQueueSize, tc.window, now)
+			if got != tc.want {
+				t.Errorf("got '%s'; want '%s'", got, tc.want)
+			} else {
+				t.Logf("got '%s'; want '%s'", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package terminator
+
+import (
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+const (
+	// databaseIdleTimeout is the time for idle connections to other databases to be closed
+	databaseIdleTimeout = time.Minute
+	// databaseRetryInterval is the time to wait before connecting again to a database that
+	// could not be reached
+	databaseRetryInterval = time.Minute
+)
+
+// database returns a connection to a database, used to resolve names of relations
+// The main connection is used for the current database. Connections to other databases are
+// kept for next calls and closed when idle. Databases that can't be reached, like databases
+// not allowing connections, are retried after an interval and no connection is returned
+// meanwhile.
+func (t *Terminator) database(name string) (*base.Db, error) {
+	if t.currentDatabase == "" {
+		current, err := t.db.CurrentDatabase()
+		if err != nil {
+			return nil, err
+		}
+		t.currentDatabase = current
+	}
+	if name == t.currentDatabase {
+		return t.db, nil
+	}
+	if db, ok := t.databases[name]; ok {
+		return db, nil
+	}
+	if failed, ok := t.unreachableDatabases[name]; ok && time.Since(failed) < databaseRetryInterval {
+		return nil, nil
+	}
+
+	db := base.NewDb(t.config.DatabaseDsn(name))
+	if err := db.Open(); err != nil {
+		t.unreachableDatabases[name] = time.Now()
+		return nil, err
+	}
+	delete(t.unreachableDatabases, name)
+	db.SetIdleTimeout(databaseIdleTimeout)
+	t.databases[name] = db
+	return db, nil
+}
+
+// closeDatabases closes connections to other databases
+func (t *Terminator) closeDatabases() {
+	for name, db := range t.databases {
+		log.Debugf("Disconnecting from database %s\n", name)
+		db.Disconnect()
+		delete(t.databases, name)
+	}
+}
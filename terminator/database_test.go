@@ -0,0 +1,36 @@
+package terminator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestDatabase(t *testing.T) {
+	main := base.NewDb("")
+	terminator := &Terminator{
+		config:               &base.Config{Host: "127.0.0.1", Port: 1, ConnectTimeout: 1},
+		db:                   main,
+		currentDatabase:      "main",
+		databases:            make(map[string]*base.Db),
+		unreachableDatabases: make(map[string]time.Time),
+	}
+
+	if db, err := terminator.database("main"); db != main || err != nil {
+		t.Errorf("got %v, %v; want main connection", db, err)
+	}
+
+	if _, err := terminator.database("other"); err == nil {
+		t.Errorf("got no error; want error for unreachable database")
+	}
+	if _, ok := terminator.unreachableDatabases["other"]; !ok {
+		t.Errorf("got database not marked unreachable; want unreachable")
+	}
+
+	if db, err := terminator.database("other"); db != nil || err != nil {
+		t.Errorf("got %v, %v; want no connection and no error before retry", db, err)
+	} else {
+		t.Logf("got no connection and no error before retry")
+	}
+}
@@ -0,0 +1,146 @@
+package terminator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// DrainOptions describes the database to drain and how long to wait for its sessions
+type DrainOptions struct {
+	Database            string
+	MaintenanceDatabase string
+	Grace               float64
+	Timeout             float64
+}
+
+// Drain blocks new connections to a database and kills its sessions
+// Idle sessions are terminated during the grace period, then remaining sessions are
+// cancelled or terminated until the timeout. Protected and filtered sessions are not killed
+// and must leave by themselves. The database is left blocked when drained, or allowed again
+// on failure or interruption when it was allowed before.
+func (t *Terminator) Drain(options DrainOptions) error {
+	t.db = base.NewDb(t.config.DatabaseDsn(options.MaintenanceDatabase))
+	if err := t.db.Open(); err != nil {
+		return err
+	}
+	defer t.db.Disconnect()
+	return t.drain(options)
+}
+
+// drain blocks new connections to a database and waits for its sessions to leave
+// Queries return errors instead of exiting, so connections are allowed again on failure.
+func (t *Terminator) drain(options DrainOptions) (err error) {
+	allowed, err := t.db.AllowConnections(options.Database)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		if err = t.db.SetAllowConnections(options.Database, false); err != nil {
+			return err
+		}
+		t.notify([]*base.Session{{Db: options.Database, Reason: "allow_connections=false"}}, base.DrainEvent)
+		defer func() {
+			if err != nil {
+				t.allowConnections(options.Database)
+			}
+		}()
+	} else {
+		log.Warnf("Database %s already blocks new connections\n", options.Database)
+	}
+
+	if t.config.HasRoleFilters() {
+		if t.roles, err = t.db.QueryRoles(); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	for {
+		elapsed := time.Since(start).Seconds()
+		var sessions []*base.Session
+		if sessions, err = t.db.QuerySessions(); err != nil {
+			return err
+		}
+		sessions = databaseSessions(sessions, options.Database)
+		if len(sessions) == 0 {
+			log.Infof("Database %s drained\n", options.Database)
+			return nil
+		}
+		if elapsed > options.Grace+options.Timeout {
+			return fmt.Errorf("%d sessions still connected to database %s", len(sessions), options.Database)
+		}
+		log.Infof("Waiting for %d sessions connected to database %s\n", len(sessions), options.Database)
+
+		t.annotate(sessions)
+		var offenders, killed []*base.Session
+		if offenders, err = t.queryProtect(t.filter(drainSessions(sessions, elapsed > options.Grace))); err != nil {
+			return err
+		}
+		if killed, _, err = t.signal(offenders); err != nil {
+			return err
+		}
+		t.notify(killed, base.DrainEvent)
+
+		select {
+		case <-t.done:
+			return fmt.Errorf("drain of database %s interrupted", options.Database)
+		case <-time.After(time.Duration(t.config.Interval*1000) * time.Millisecond):
+		}
+	}
+}
+
+// Undrain allows new connections to a database again
+func (t *Terminator) Undrain(options DrainOptions) error {
+	t.db = base.NewDb(t.config.DatabaseDsn(options.MaintenanceDatabase))
+	if err := t.db.Open(); err != nil {
+		return err
+	}
+	defer t.db.Disconnect()
+
+	if _, err := t.db.AllowConnections(options.Database); err != nil {
+		return err
+	}
+	if err := t.db.SetAllowConnections(options.Database, true); err != nil {
+		return err
+	}
+	t.notify([]*base.Session{{Db: options.Database, Reason: "allow_connections=true"}}, base.UndrainEvent)
+	return nil
+}
+
+// allowConnections allows new connections to a database again after a failed drain
+func (t *Terminator) allowConnections(database string) {
+	if err := t.db.SetAllowConnections(database, true); err != nil {
+		log.Errorf("Cannot allow connections to database %s again: %v\n", database, err)
+		return
+	}
+	t.notify([]*base.Session{{Db: database, Reason: "allow_connections=true"}}, base.UndrainEvent)
+}
+
+// databaseSessions returns sessions connected to a database
+func databaseSessions(sessions []*base.Session, database string) (result []*base.Session) {
+	for _, session := range sessions {
+		if session.Db == database {
+			result = append(result, session)
+		}
+	}
+	return result
+}
+
+// drainSessions returns idle sessions during the grace period, or all sessions once the grace
+// period is over, with the stage of the drain as reason
+func drainSessions(sessions []*base.Session, graceOver bool) (result []*base.Session) {
+	for _, session := range sessions {
+		switch {
+		case graceOver:
+			session.Reason = "drain=timeout"
+		case session.State == "idle":
+			session.Reason = "drain=grace"
+		default:
+			continue
+		}
+		result = append(result, session)
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package terminator
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestDrainFailure(t *testing.T) {
+	sessions := make(chan *base.Session, 10)
+	terminator := NewTerminator(base.NewContext(&base.Config{}, sessions, make(chan bool)))
+	var fake *fakeDriver
+	terminator.db, fake = newFakeDb(t,
+		fakeResponse{match: "datallowconn", rows: [][]driver.Value{{true}}},
+		fakeResponse{match: "server_version_num", err: errors.New("connection lost")},
+	)
+
+	if err := terminator.drain(DrainOptions{Database: "app"}); err == nil {
+		t.Errorf("got no error; want error when sessions can't be listed")
+	}
+
+	want := []string{`alter database "app" allow_connections false;`, `alter database "app" allow_connections true;`}
+	if !reflect.DeepEqual(fake.executed, want) {
+		t.Errorf("got %+v; want %+v", fake.executed, want)
+	} else {
+		t.Logf("got %+v; want %+v", fake.executed, want)
+	}
+}
+
+func TestDrainSessions(t *testing.T) {
+	sessions := databaseSessions([]*base.Session{
+		{User: "idle", Db: "app", State: "idle"},
+		{User: "active", Db: "app", State: "active"},
+		{User: "transaction", Db: "app", State: "idle in transaction"},
+		{User: "other", Db: "postgres", State: "idle"},
+	}, "app")
+
+	tests := []struct {
+		name      string
+		graceOver bool
+		want      []string
+	}{
+		{"Grace period", false, []string{"idle"}},
+		{"Grace period over", true, []string{"idle", "active", "transaction"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(drainSessions(sessions, tc.graceOver))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDrainProtected(t *testing.T) {
+	tests := []struct {
+		name            string
+		applicationName string
+		want            bool
+	}{
+		{"Session of a client is terminated", "psql", true},
+		{"Session of a backup is protected", "pg_dump", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator, fake := newFakeTerminator(t, &base.Config{},
+				fakeResponse{match: "datallowconn", rows: [][]driver.Value{{false}}},
+				fakeResponse{match: "from pg_catalog.pg_stat_activity\n\twhere pid <> pg_backend_pid()", rows: [][]driver.Value{
+					{int64(1), "app", "app", "localhost", "active", "select 1", float64(10), tc.applicationName, "client backend", "", int64(0), nil},
+				}},
+				fakeResponse{match: "pg_terminate_backend", rows: [][]driver.Value{{int64(1), true}}},
+			)
+			close(terminator.done)
+			terminator.drain(DrainOptions{Database: "app", Timeout: 60})
+			got := fake.signaled()
+			if got != tc.want {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
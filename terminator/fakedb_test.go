@@ -0,0 +1,128 @@
+package terminator
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+// fakeResponse answers queries containing a text with rows or an error
+type fakeResponse struct {
+	match string
+	rows  [][]driver.Value
+	err   error
+}
+
+// fakeDriver is a database/sql driver answering queries with the first matching response,
+// failing unknown queries and recording executed statements
+type fakeDriver struct {
+	responses []fakeResponse
+	mutex     sync.Mutex
+	executed  []string
+}
+
+var fakeDrivers int
+
+// newFakeDb registers a fake driver answering with responses and returns a Db using it
+func newFakeDb(t *testing.T, responses ...fakeResponse) (*base.Db, *fakeDriver) {
+	fake := &fakeDriver{responses: responses}
+	fakeDrivers++
+	name := fmt.Sprintf("fake%d", fakeDrivers)
+	sql.Register(name, fake)
+	db := base.NewDriverDb(name, "")
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Disconnect)
+	return db, fake
+}
+
+// signaled returns true when a statement cancelling or terminating backends has been executed
+func (d *fakeDriver) signaled() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, statement := range d.executed {
+		if strings.Contains(statement, "pg_cancel_backend") || strings.Contains(statement, "pg_terminate_backend") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+type fakeConn struct{ driver *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.driver, query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	driver *fakeDriver
+	query  string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mutex.Lock()
+	defer s.driver.mutex.Unlock()
+	s.driver.executed = append(s.driver.executed, s.query)
+	return driver.ResultNoRows, nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.Contains(s.query, "pg_cancel_backend") || strings.Contains(s.query, "pg_terminate_backend") {
+		s.driver.mutex.Lock()
+		s.driver.executed = append(s.driver.executed, s.query)
+		s.driver.mutex.Unlock()
+	}
+	for _, response := range s.driver.responses {
+		if strings.Contains(s.query, response.match) {
+			if response.err != nil {
+				return nil, response.err
+			}
+			return &fakeRows{rows: response.rows}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return []string{"value"}
+	}
+	return make([]string, len(r.rows[0]))
+}
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newFakeTerminator returns a terminator using a fake driver answering with responses
+// Instances have no progress view so sessions are not running maintenance operations
+func newFakeTerminator(t *testing.T, config *base.Config, responses ...fakeResponse) (*Terminator, *fakeDriver) {
+	terminator := NewTerminator(base.NewContext(config, make(chan *base.Session, 10), make(chan bool)))
+	responses = append(responses,
+		fakeResponse{match: "server_version_num", rows: [][]driver.Value{{int64(150000)}}},
+		fakeResponse{match: "pg_stat_progress_"},
+	)
+	var fake *fakeDriver
+	terminator.db, fake = newFakeDb(t, responses...)
+	return terminator, fake
+}
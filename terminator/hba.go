@@ -0,0 +1,85 @@
+package terminator
+
+import (
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// hba reports sessions rejected by current pg_hba.conf rules and terminates them when
+// configured
+// As pg_hba_file_rules shows the file on disk, sessions are checked once the configuration
+// has been reloaded, polled every check interval, and not while the file has been modified
+// after the reload. Sessions are not checked when a rule has an error because PostgreSQL
+// keeps previous rules in that case.
+func (t *Terminator) hba() {
+	if !t.config.HbaCheck {
+		return
+	}
+	t.mutex.Lock()
+	if time.Since(t.hbaChecked).Seconds() < t.config.HbaCheckInterval {
+		t.mutex.Unlock()
+		return
+	}
+	t.hbaChecked = time.Now()
+	loaded := t.hbaLoaded
+	t.mutex.Unlock()
+
+	file, err := t.db.HbaFile()
+	if err != nil {
+		log.Errorf("Cannot read pg_hba.conf load time: %v\n", err)
+		return
+	}
+	if !file.Reloaded(loaded) {
+		if file.Modified.After(file.Loaded) {
+			log.Debug("Skipping pg_hba.conf check, file has been modified since last reload")
+		}
+		return
+	}
+	t.mutex.Lock()
+	t.hbaLoaded = file.Loaded
+	t.mutex.Unlock()
+
+	log.Debug("Checking sessions against pg_hba.conf rules")
+	rules, err := t.db.HbaRules()
+	if err != nil {
+		log.Errorf("Cannot read pg_hba.conf rules: %v\n", err)
+		return
+	}
+	for _, rule := range rules {
+		if rule.Error != "" {
+			log.Warnf("Skipping pg_hba.conf check, line %d has an error: %s\n", rule.Line, rule.Error)
+			return
+		}
+	}
+
+	targets := t.protect(t.filter(rejectedSessions(rules, t.db.Encryptions(), t.db.Roles())))
+	if t.config.HbaTerminate {
+		t.db.TerminateSessions(targets)
+	}
+	t.notify(targets, base.HbaEvent)
+}
+
+// rejectedSessions returns sessions matching a "reject" rule or no rule at all with the rule
+// as reason
+// Sessions that can't be evaluated are ignored
+func rejectedSessions(rules []*base.HbaRule, encryptions []*base.Encryption, roles map[string]*base.Role) (result []*base.Session) {
+	for _, encryption := range encryptions {
+		rule, err := base.EvaluateHba(rules, encryption, roles[encryption.Session.User])
+		if err != nil {
+			log.Debugf("Cannot check session %d against pg_hba.conf rules: %v\n", encryption.Session.Pid, err)
+			continue
+		}
+		switch {
+		case rule == nil:
+			encryption.Session.Reason = "line=none"
+		case rule.Method == "reject":
+			encryption.Session.Reason = rule.String()
+		default:
+			continue
+		}
+		result = append(result, encryption.Session)
+	}
+	return result
+}
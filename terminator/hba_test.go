@@ -0,0 +1,32 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestRejectedSessions(t *testing.T) {
+	rules := []*base.HbaRule{
+		{Line: 1, Type: "host", Databases: []string{"all"}, Users: []string{"intruder"}, Address: "all", Method: "reject"},
+		{Line: 2, Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: ".example.com", Method: "trust"},
+		{Line: 3, Type: "host", Databases: []string{"all"}, Users: []string{"all"}, Address: "10.0.0.0", Netmask: "255.0.0.0", Method: "trust"},
+	}
+	encryptions := []*base.Encryption{
+		{Session: &base.Session{User: "intruder", Client: "10.0.0.1:5432"}},
+		{Session: &base.Session{User: "local"}, Local: true},
+		{Session: &base.Session{User: "unknown", Client: "10.0.0.1:5432"}},
+	}
+
+	got := rejectedSessions(rules, encryptions, nil)
+	want := []*base.Session{
+		{User: "intruder", Client: "10.0.0.1:5432", Reason: "line=1 method=reject"},
+		{User: "local", Reason: "line=none"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", ListUsers(got), ListUsers(want))
+	} else {
+		t.Logf("got %+v; want %+v", ListUsers(got), ListUsers(want))
+	}
+}
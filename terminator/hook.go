@@ -0,0 +1,103 @@
+package terminator
+
+import (
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// decide asks the policy hook what to do with sessions and returns sessions to cancel or
+// terminate
+// Sessions without decision are returned in fail-open mode and left alone in fail-closed
+// mode. Skipped sessions are not sent to the hook anymore.
+// Only active, idle and adaptive rules ask the hook, other rules kill sessions directly.
+func (t *Terminator) decide(sessions []*base.Session, event string) (result []*base.Session) {
+	t.reloadHook()
+	if t.hook == nil {
+		return sessions
+	}
+
+	var candidates []*base.Session
+	for _, session := range sessions {
+		if !t.skipped(session) {
+			candidates = append(candidates, session)
+		}
+	}
+
+	decisions, err := t.hook.Decide(candidates, event, time.Duration(t.config.HookTimeout*1000)*time.Millisecond)
+	if err != nil {
+		log.Errorf("Policy hook: %v\n", err)
+	}
+
+	for _, session := range candidates {
+		decision, ok := decisions[session.Pid]
+		if !ok {
+			if t.config.HookFailMode != base.FailClosed {
+				result = append(result, session)
+			}
+			continue
+		}
+		log.Debugf("Policy hook: decision=%s reason=%s for pid %d\n", decision.Decision, decision.Reason, session.Pid)
+		switch decision.Decision {
+		case base.SkipDecision:
+			t.skippedSessions[session.Pid] = session.BackendStart
+		case base.TerminateDecision, base.CancelDecision:
+			session.Action = decision.Decision
+			if decision.Reason != "" {
+				session.Reason = decision.Reason
+			}
+			result = append(result, session)
+		}
+	}
+	return result
+}
+
+// reloadHook starts the policy hook or restarts it when the configuration has been
+// reloaded
+func (t *Terminator) reloadHook() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.hook != nil && !t.hookReloaded {
+		return
+	}
+	t.hookReloaded = false
+	if t.hook != nil {
+		t.hook.Stop()
+		t.hook = nil
+	}
+	if t.config.Hook != "" {
+		t.hook = base.NewHook(t.config.Hook)
+	}
+}
+
+// terminateHook stops the policy hook
+func (t *Terminator) terminateHook() {
+	if t.hook != nil {
+		log.Info("Stopping policy hook")
+		t.hook.Stop()
+	}
+}
+
+// skipped returns true when the policy hook has skipped the session
+// Sessions are identified by process id and backend start, so a new backend reusing the
+// process id of a skipped session is not skipped.
+func (t *Terminator) skipped(session *base.Session) bool {
+	start, ok := t.skippedSessions[session.Pid]
+	return ok && start.Equal(session.BackendStart)
+}
+
+// forgetSkipped removes sessions skipped by the policy hook that have ended
+func (t *Terminator) forgetSkipped(sessions []*base.Session) {
+	alive := make(map[int64]bool)
+	for _, session := range sessions {
+		if t.skipped(session) {
+			alive[session.Pid] = true
+		}
+	}
+	for pid := range t.skippedSessions {
+		if !alive[pid] {
+			delete(t.skippedSessions, pid)
+		}
+	}
+}
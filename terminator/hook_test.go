@@ -0,0 +1,89 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name     string
+		hook     string
+		failMode string
+		want     []int64
+		skipped  []int64
+	}{
+		{"No hook", "", base.FailOpen, []int64{1, 2, 3}, nil},
+		{
+			"Decisions",
+			`while read -r line; do
+				id=$(echo "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+				case $id in
+					1) echo "{\"id\":$id,\"decision\":\"cancel\"}" ;;
+					2) echo "{\"id\":$id,\"decision\":\"skip\"}" ;;
+					3) echo "{\"id\":$id,\"decision\":\"postpone\"}" ;;
+				esac
+			done`,
+			base.FailOpen,
+			[]int64{1},
+			[]int64{2},
+		},
+		{"Fail open", "cat > /dev/null", base.FailOpen, []int64{1, 2, 3}, nil},
+		{"Fail closed", "cat > /dev/null", base.FailClosed, nil, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sessions := []*base.Session{{Pid: 1}, {Pid: 2}, {Pid: 3}}
+			terminator := &Terminator{
+				config:          &base.Config{Hook: tc.hook, HookTimeout: 0.2, HookFailMode: tc.failMode},
+				skippedSessions: make(map[int64]time.Time),
+			}
+			defer terminator.terminateHook()
+
+			var got, skipped []int64
+			for _, session := range terminator.decide(sessions, base.ActiveEvent) {
+				got = append(got, session.Pid)
+			}
+			for _, session := range sessions {
+				if terminator.skipped(session) {
+					skipped = append(skipped, session.Pid)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) || !reflect.DeepEqual(skipped, tc.skipped) {
+				t.Errorf("got %v (skipped %v); want %v (skipped %v)", got, skipped, tc.want, tc.skipped)
+			} else {
+				t.Logf("got %v (skipped %v); want %v (skipped %v)", got, skipped, tc.want, tc.skipped)
+			}
+		})
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	terminator := &Terminator{skippedSessions: map[int64]time.Time{1: start}}
+
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    bool
+	}{
+		{"Skipped session", &base.Session{Pid: 1, BackendStart: start}, true},
+		{"Process id reused by a new backend", &base.Session{Pid: 1, BackendStart: start.Add(time.Minute)}, false},
+		{"Other session", &base.Session{Pid: 2, BackendStart: start}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := terminator.skipped(tc.session)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package terminator
+
+import (
+	"github.com/jouir/pgterminate/base"
+)
+
+// KillOptions describes sessions to kill once with the kill command, in addition to filters
+// An empty list of states matches any state
+type KillOptions struct {
+	States      []string
+	MinDuration float64
+}
+
+// Connect connects to the instance for commands running once
+func (t *Terminator) Connect() error {
+	t.db = base.NewDb(t.config.Dsn())
+	return t.db.Open()
+}
+
+// Disconnect disconnects from the instance for commands running once
+func (t *Terminator) Disconnect() {
+	t.db.Disconnect()
+}
+
+// Candidates returns sessions matching states, minimum duration and filters, without
+// protected sessions
+// Policies and annotations are attached to decide whether to cancel or terminate them.
+func (t *Terminator) Candidates(options KillOptions) []*base.Session {
+	sessions := t.db.Sessions()
+	t.refreshRoles()
+	t.refreshPolicies()
+	t.importQueryIDs()
+	t.label(sessions)
+	t.assignPolicies(sessions)
+	t.annotate(sessions)
+	return labelSessions(t.protect(t.filter(killSessions(sessions, options.States, options.MinDuration))))
+}
+
+// Recheck returns candidates still matching options, like after a slow confirmation, and
+// candidates that have ended or don't match anymore
+func (t *Terminator) Recheck(candidates []*base.Session, options KillOptions) (matching []*base.Session, changed []*base.Session) {
+	return stillCandidates(candidates, t.Candidates(options))
+}
+
+// stillCandidates returns current candidates among previous candidates, and previous
+// candidates missing from current ones
+// Candidates are compared by process id and backend start time, so a process id reused by a
+// new backend doesn't match.
+func stillCandidates(previous []*base.Session, current []*base.Session) (matching []*base.Session, changed []*base.Session) {
+	for _, candidate := range previous {
+		found := false
+		for _, session := range current {
+			if session.Pid == candidate.Pid && session.BackendStart.Equal(candidate.BackendStart) {
+				matching = append(matching, session)
+				found = true
+				break
+			}
+		}
+		if !found {
+			changed = append(changed, candidate)
+		}
+	}
+	return matching, changed
+}
+
+// Kill cancels or terminates sessions depending on their policy or the cancel option and
+// returns sessions that could not be signaled, like sessions gone in the meantime
+// Idle sessions are always terminated because cancelling them has no effect.
+func (t *Terminator) Kill(sessions []*base.Session) (failed []*base.Session, err error) {
+	killed, failed, err := t.signal(sessions)
+	if err != nil {
+		return nil, err
+	}
+	t.notify(killed, base.KillEvent)
+	return failed, nil
+}
+
+// signal cancels or terminates sessions like kill and returns sessions signaled successfully
+// and sessions that could not be signaled, or an error instead of exiting
+func (t *Terminator) signal(sessions []*base.Session) (killed []*base.Session, failed []*base.Session, err error) {
+	var cancels, terminates []*base.Session
+	for _, session := range sessions {
+		if t.cancel(session) && !session.IsIdle() {
+			cancels = append(cancels, session)
+		} else {
+			terminates = append(terminates, session)
+		}
+	}
+
+	for _, group := range []struct {
+		sessions []*base.Session
+		cancel   bool
+	}{{cancels, true}, {terminates, false}} {
+		signaled, err := t.db.SignalSessions(group.sessions, group.cancel)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, session := range group.sessions {
+			if signaled[session.Pid] {
+				killed = append(killed, session)
+			} else {
+				failed = append(failed, session)
+			}
+		}
+	}
+	return killed, failed, nil
+}
+
+// killSessions returns sessions in one of the states for longer than the minimum duration in
+// seconds
+func killSessions(sessions []*base.Session, states []string, minDuration float64) (result []*base.Session) {
+	for _, session := range sessions {
+		if (len(states) == 0 || base.InSlice(session.State, states)) && session.StateDuration >= minDuration {
+			result = append(result, session)
+		}
+	}
+	return result
+}
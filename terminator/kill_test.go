@@ -0,0 +1,62 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestKillSessions(t *testing.T) {
+	sessions := []*base.Session{
+		{User: "active", State: "active", StateDuration: 60},
+		{User: "idle", State: "idle", StateDuration: 60},
+		{User: "transaction", State: "idle in transaction", StateDuration: 60},
+		{User: "short", State: "idle in transaction", StateDuration: 10},
+	}
+
+	tests := []struct {
+		name        string
+		states      []string
+		minDuration float64
+		want        []string
+	}{
+		{"Any state", nil, 0, []string{"active", "idle", "transaction", "short"}},
+		{"States", []string{"idle", "idle in transaction"}, 0, []string{"idle", "transaction", "short"}},
+		{"Minimum duration", []string{"idle in transaction"}, 30, []string{"transaction"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(killSessions(sessions, tc.states, tc.minDuration))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStillCandidates(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC)
+	previous := []*base.Session{
+		{Pid: 1, User: "same", BackendStart: start},
+		{Pid: 2, User: "reused", BackendStart: start},
+		{Pid: 3, User: "ended", BackendStart: start},
+	}
+	current := []*base.Session{
+		{Pid: 1, User: "same", BackendStart: start.In(time.Local)},
+		{Pid: 2, User: "new", BackendStart: start.Add(time.Second)},
+	}
+
+	matching, changed := stillCandidates(previous, current)
+	got := [][]string{ListUsers(matching), ListUsers(changed)}
+	want := [][]string{{"same"}, {"reused", "ended"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	} else {
+		t.Logf("got %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,18 @@
+package terminator
+
+import (
+	"github.com/jouir/pgterminate/base"
+)
+
+// label parses application names of sessions into labels when a labels parser is configured
+// Sessions already labelled are left untouched
+func (t *Terminator) label(sessions []*base.Session) {
+	if t.config.LabelsParser == nil {
+		return
+	}
+	for _, session := range sessions {
+		if session.Labels == nil {
+			session.Labels = t.config.LabelsParser.Parse(session.ApplicationName)
+		}
+	}
+}
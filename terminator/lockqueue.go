@@ -0,0 +1,55 @@
+package terminator
+
+import (
+	"fmt"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+// lockQueues cancels DDL or terminates sessions blocking them when too many sessions
+// are waiting behind the DDL
+func (t *Terminator) lockQueues(sessions []*base.Session) {
+	if t.config.LockQueueSize == 0 {
+		return
+	}
+	for _, queue := range t.db.LockQueues() {
+		if queue.Waiters < t.config.LockQueueSize {
+			continue
+		}
+		targets := t.protect(t.filter(lockQueueTargets(queue, sessions, t.config.LockQueueAction, t.config.LockQueueIdleInTransaction)))
+		for _, target := range targets {
+			target.Reason = fmt.Sprintf("ddl_pid=%d relation=%s mode=%s waiters=%d", queue.Pid, queue.Relation, queue.Mode, queue.Waiters)
+		}
+		if t.config.LockQueueAction == base.TerminateBlockers {
+			t.db.TerminateSessions(targets)
+		} else {
+			t.db.CancelSessions(targets)
+		}
+		t.notify(targets, base.LockQueueEvent)
+	}
+}
+
+// lockQueueTargets returns sessions to act on for a lock queue
+// The DDL session is returned when action is to cancel the DDL, blocking sessions otherwise
+func lockQueueTargets(queue *base.LockQueue, sessions []*base.Session, action string, idleInTransaction bool) (result []*base.Session) {
+	for _, session := range sessions {
+		if action == base.TerminateBlockers {
+			if pidInSlice(session.Pid, queue.Blockers) && (!idleInTransaction || session.IsIdleInTransaction()) {
+				result = append(result, session)
+			}
+		} else if session.Pid == queue.Pid {
+			result = append(result, session)
+		}
+	}
+	return result
+}
+
+// pidInSlice returns true when a process id is present in the slice
+func pidInSlice(pid int64, pids []int64) bool {
+	for _, p := range pids {
+		if pid == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package terminator
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestLockQueueTargets(t *testing.T) {
+
+	sessions := []*base.Session{
+		{Pid: 1, User: "migration", State: "active"},
+		{Pid: 2, User: "test", State: "idle in transaction"},
+		{Pid: 3, User: "test_1", State: "active"},
+		{Pid: 4, User: "test_2", State: "active"},
+	}
+	queue := &base.LockQueue{Pid: 1, Blockers: []int64{2, 3}, Waiters: 10}
+
+	tests := []struct {
+		name              string
+		action            string
+		idleInTransaction bool
+		want              []string
+	}{
+		{"Cancel DDL", base.CancelDDL, false, []string{"migration"}},
+		{"Terminate blockers", base.TerminateBlockers, false, []string{"test", "test_1"}},
+		{"Terminate blockers idle in transaction", base.TerminateBlockers, true, []string{"test"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(lockQueueTargets(queue, sessions, tc.action, tc.idleInTransaction))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLockQueuesProtected(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    bool
+	}{
+		{"Blocker of a client is terminated", &base.Session{Pid: 1, State: "idle in transaction", ApplicationName: "psql"}, true},
+		{"Blocker of a backup is protected", &base.Session{Pid: 1, State: "active", ApplicationName: "pg_dump"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator, fake := newFakeTerminator(t, &base.Config{LockQueueSize: 1, LockQueueAction: base.TerminateBlockers},
+				fakeResponse{match: "pg_blocking_pids", rows: [][]driver.Value{{int64(2), "t", "AccessExclusiveLock", []byte("{1}"), int64(5)}}},
+				fakeResponse{match: "pg_terminate_backend"},
+			)
+			terminator.lockQueues([]*base.Session{tc.session})
+			got := fake.signaled()
+			if got != tc.want {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
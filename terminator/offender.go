@@ -0,0 +1,256 @@
+package terminator
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// offenderKey identifies a client by role, application name and client address, so
+// reconnections from another port are counted together
+type offenderKey struct {
+	user            string
+	applicationName string
+	client          string
+}
+
+// newOffenderKey returns the key of the client of a session
+func newOffenderKey(session *base.Session) offenderKey {
+	return offenderKey{user: session.User, applicationName: session.ApplicationName, client: session.ClientAddr()}
+}
+
+// offender tracks kills of a client in the window and the end of its cool-down once it has
+// been reported as repeat offender
+type offender struct {
+	kills []time.Time
+	until time.Time
+}
+
+// killEvent returns true when sessions notified with the event have been cancelled or
+// terminated
+func (t *Terminator) killEvent(event string) bool {
+	switch event {
+	case base.ProtectedEvent, base.HungBackupEvent, base.SlotEvent, base.RepeatOffenderEvent, base.ThrottleEvent, base.UnthrottleEvent, base.UndrainEvent:
+		return false
+	case base.HbaEvent:
+		return t.config.HbaTerminate
+	}
+	return true
+}
+
+// recordKills counts kills of client sessions by client
+func (t *Terminator) recordKills(sessions []*base.Session, now time.Time) {
+	for _, session := range sessions {
+		if session.User == "" {
+			continue
+		}
+		key := newOffenderKey(session)
+		o, ok := t.offenders[key]
+		if !ok {
+			o = &offender{}
+			t.offenders[key] = o
+		}
+		o.kills = append(o.kills, now)
+	}
+}
+
+// repeatOffenders reports clients killed too many times in the window and throttles their
+// role until the end of the cool-down when configured
+func (t *Terminator) repeatOffenders() {
+	if t.config.RepeatOffenderKills == 0 {
+		return
+	}
+	now := time.Now()
+	window := time.Duration(t.config.RepeatOffenderWindow*1000) * time.Millisecond
+	cooldown := time.Duration(t.config.RepeatOffenderCooldown*1000) * time.Millisecond
+	offenders := detectOffenders(t.offenders, now, t.config.RepeatOffenderKills, window, cooldown)
+	t.notify(offenders, base.RepeatOffenderEvent)
+
+	if t.config.RepeatOffenderConnectionLimit >= 0 {
+		for _, session := range offenders {
+			t.throttleRole(session.User, now.Add(cooldown))
+		}
+	}
+	t.restoreRoles(now, false)
+}
+
+// detectOffenders forgets kills older than the window and clients at the end of their
+// cool-down, and returns clients reaching the number of kills as sessions with the number of
+// kills as reason
+// Reported clients are not reported again until the end of their cool-down.
+func detectOffenders(offenders map[offenderKey]*offender, now time.Time, kills int, window time.Duration, cooldown time.Duration) (result []*base.Session) {
+	for key, o := range offenders {
+		if !o.until.IsZero() {
+			if now.After(o.until) {
+				delete(offenders, key)
+			}
+			continue
+		}
+		var recent []time.Time
+		for _, kill := range o.kills {
+			if now.Sub(kill) <= window {
+				recent = append(recent, kill)
+			}
+		}
+		o.kills = recent
+		if len(o.kills) == 0 {
+			delete(offenders, key)
+			continue
+		}
+		if len(o.kills) >= kills {
+			o.until = now.Add(cooldown)
+			result = append(result, &base.Session{
+				User:            key.user,
+				ApplicationName: key.applicationName,
+				Client:          key.client,
+				Reason:          fmt.Sprintf("kills=%d window=%s until=%s", len(o.kills), window, o.until.Format(time.RFC3339)),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].User != result[j].User {
+			return result[i].User < result[j].User
+		}
+		if result[i].ApplicationName != result[j].ApplicationName {
+			return result[i].ApplicationName < result[j].ApplicationName
+		}
+		return result[i].Client < result[j].Client
+	})
+	return result
+}
+
+// offending returns true when the client of a session is a repeat offender in cool-down
+func (t *Terminator) offending(session *base.Session) bool {
+	o, ok := t.offenders[newOffenderKey(session)]
+	return ok && !o.until.IsZero()
+}
+
+// offenderPolicy returns the policy applied to repeat offenders or nil
+func (t *Terminator) offenderPolicy(policies []*base.Policy) *base.Policy {
+	if t.config.RepeatOffenderPolicy == "" {
+		return nil
+	}
+	for _, policy := range policies {
+		if policy.Name == t.config.RepeatOffenderPolicy {
+			return policy
+		}
+	}
+	log.Warnf("Policy %s of repeat offenders not found\n", t.config.RepeatOffenderPolicy)
+	return nil
+}
+
+// loadThrottles reads roles throttled before a restart from the state file and restores
+// those at the end of their cool-down, so limits lowered before a crash are not kept forever
+func (t *Terminator) loadThrottles() {
+	if t.config.RepeatOffenderStateFile == "" {
+		return
+	}
+	throttles, err := base.ReadThrottles(t.config.RepeatOffenderStateFile)
+	if err != nil {
+		log.Warnf("Cannot read state file %s: %v\n", t.config.RepeatOffenderStateFile, err)
+		return
+	}
+	for role, th := range throttles {
+		log.Infof("Role %s throttled to %d until %s before restart\n", role, th.Limit, th.Until.Format(time.RFC3339))
+	}
+	t.throttles = throttles
+	t.restoreRoles(time.Now(), false)
+}
+
+// saveThrottles writes throttled roles to the state file
+func (t *Terminator) saveThrottles() {
+	if t.config.RepeatOffenderStateFile == "" {
+		return
+	}
+	if err := base.WriteThrottles(t.config.RepeatOffenderStateFile, t.throttles); err != nil {
+		log.Errorf("Cannot write state file %s: %v\n", t.config.RepeatOffenderStateFile, err)
+	}
+}
+
+// throttleRole lowers the connection limit of a role until the end of the cool-down and
+// notifies the change
+// The cool-down is extended when the role is already throttled. Roles with a lower limit
+// are not changed. The throttle is saved before lowering the limit, so it can be restored
+// after a crash.
+func (t *Terminator) throttleRole(role string, until time.Time) {
+	if th, ok := t.throttles[role]; ok {
+		if until.After(th.Until) {
+			th.Until = until
+			t.saveThrottles()
+		}
+		return
+	}
+	limit := t.config.RepeatOffenderConnectionLimit
+	previous, err := t.db.ConnectionLimit(role)
+	if err != nil {
+		log.Errorf("Cannot read connection limit of role %s: %v\n", role, err)
+		return
+	}
+	if previous != -1 && previous <= limit {
+		return
+	}
+	t.throttles[role] = &base.Throttle{Previous: previous, Limit: limit, Until: until}
+	t.saveThrottles()
+	if err = t.db.SetConnectionLimit(role, limit); err != nil {
+		log.Errorf("Cannot lower connection limit of role %s: %v\n", role, err)
+		delete(t.throttles, role)
+		t.saveThrottles()
+		return
+	}
+	t.notify([]*base.Session{{
+		User:   role,
+		Reason: fmt.Sprintf("connlimit=%d previous=%d until=%s", limit, previous, until.Format(time.RFC3339)),
+	}}, base.ThrottleEvent)
+}
+
+// restoreRoles restores connection limits of roles at the end of their cool-down, or of all
+// throttled roles when shutting down
+// Limits changed during the cool-down, like by an operator, and roles dropped meanwhile are
+// left as is. Changes are logged instead of notified when shutting down because notifiers
+// are stopped.
+func (t *Terminator) restoreRoles(now time.Time, shutdown bool) {
+	changed := false
+	for role, th := range t.throttles {
+		if !shutdown && now.Before(th.Until) {
+			continue
+		}
+		current, err := t.db.ConnectionLimit(role)
+		if err == sql.ErrNoRows {
+			log.Warnf("Role %s has been dropped during its cool-down\n", role)
+			delete(t.throttles, role)
+			changed = true
+			continue
+		}
+		if err != nil {
+			log.Errorf("Cannot read connection limit of role %s: %v\n", role, err)
+			continue
+		}
+		if current != th.Limit {
+			log.Warnf("Connection limit of role %s changed to %d during its cool-down, not restoring it to %d\n", role, current, th.Previous)
+			delete(t.throttles, role)
+			changed = true
+			continue
+		}
+		if err := t.db.SetConnectionLimit(role, th.Previous); err != nil {
+			log.Errorf("Cannot restore connection limit of role %s: %v\n", role, err)
+			continue
+		}
+		delete(t.throttles, role)
+		changed = true
+		if shutdown {
+			log.Infof("Restored connection limit of role %s to %d\n", role, th.Previous)
+			continue
+		}
+		t.notify([]*base.Session{{
+			User:   role,
+			Reason: fmt.Sprintf("connlimit=%d", th.Previous),
+		}}, base.UnthrottleEvent)
+	}
+	if changed {
+		t.saveThrottles()
+	}
+}
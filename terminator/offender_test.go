@@ -0,0 +1,97 @@
+package terminator
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestDetectOffenders(t *testing.T) {
+	now := time.Now()
+	offenders := map[offenderKey]*offender{
+		{user: "app", applicationName: "orm", client: "10.0.0.1"}:     {kills: []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(-3 * time.Minute)}},
+		{user: "old", applicationName: "orm", client: "10.0.0.2"}:     {kills: []time.Time{now.Add(-2 * time.Hour), now.Add(-3 * time.Hour), now.Add(-time.Minute)}},
+		{user: "expired", applicationName: "orm", client: "10.0.0.3"}: {kills: []time.Time{now.Add(-2 * time.Hour)}},
+		{user: "cooling", applicationName: "orm", client: "10.0.0.4"}: {kills: []time.Time{now, now, now}, until: now.Add(time.Minute)},
+		{user: "cooled", applicationName: "orm", client: "10.0.0.5"}:  {kills: []time.Time{now, now, now}, until: now.Add(-time.Minute)},
+	}
+
+	got := ListUsers(detectOffenders(offenders, now, 3, time.Hour, time.Hour))
+	want := []string{"app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	} else {
+		t.Logf("got %+v; want %+v", got, want)
+	}
+
+	var remaining []string
+	for key := range offenders {
+		remaining = append(remaining, key.user)
+	}
+	sort.Strings(remaining)
+	want = []string{"app", "cooling", "old"}
+	if !reflect.DeepEqual(remaining, want) {
+		t.Errorf("got remaining %+v; want %+v", remaining, want)
+	}
+
+	if got = ListUsers(detectOffenders(offenders, now, 3, time.Hour, time.Hour)); got != nil {
+		t.Errorf("got %+v reported again; want no offender during cool-down", got)
+	}
+}
+
+func TestRecordKills(t *testing.T) {
+	terminator := &Terminator{
+		config:    &base.Config{RepeatOffenderKills: 2},
+		offenders: make(map[offenderKey]*offender),
+	}
+	now := time.Now()
+	terminator.recordKills([]*base.Session{
+		{User: "app", ApplicationName: "orm", Client: "10.0.0.1:50000"},
+		{User: "app", ApplicationName: "orm", Client: "10.0.0.1:50001"},
+		{Pid: 42},
+	}, now)
+
+	key := offenderKey{user: "app", applicationName: "orm", client: "10.0.0.1"}
+	if o, ok := terminator.offenders[key]; !ok || len(o.kills) != 2 || len(terminator.offenders) != 1 {
+		t.Errorf("got %+v; want 2 kills of %+v only", terminator.offenders, key)
+	}
+
+	session := &base.Session{User: "app", ApplicationName: "orm", Client: "10.0.0.1:50002"}
+	if terminator.offending(session) {
+		t.Errorf("got offending; want not offending before being reported")
+	}
+	detectOffenders(terminator.offenders, now, 2, time.Hour, time.Hour)
+	if !terminator.offending(session) {
+		t.Errorf("got not offending; want offending after reaching the number of kills")
+	}
+}
+
+func TestKillEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		hba   bool
+		want  bool
+	}{
+		{"Active", base.ActiveEvent, false, true},
+		{"Protected", base.ProtectedEvent, false, false},
+		{"Repeat offender", base.RepeatOffenderEvent, false, false},
+		{"Hba reported", base.HbaEvent, false, false},
+		{"Hba terminated", base.HbaEvent, true, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: &base.Config{HbaTerminate: tc.hba}}
+			got := terminator.killEvent(tc.event)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
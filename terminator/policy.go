@@ -0,0 +1,81 @@
+package terminator
+
+import (
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// refreshPolicies reads policies from the policies table when configured and the refresh
+// interval is elapsed
+// Previous policies are kept when new policies can't be read or are invalid
+func (t *Terminator) refreshPolicies() {
+	if t.config.PoliciesDatabase == "" {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if time.Since(t.policiesRefreshed).Seconds() < t.config.PoliciesRefreshInterval {
+		return
+	}
+	log.Debug("Refreshing policies from policies table")
+	if err := t.config.ReadPolicies(); err != nil {
+		log.Errorf("Keeping previous policies: %v\n", err)
+	}
+	t.policiesRefreshed = time.Now()
+}
+
+// importQueryIDs executes queries of policies importing query identifiers when the refresh
+// interval is elapsed or when policies have not imported identifiers yet, like policies
+// just read from the policies table
+func (t *Terminator) importQueryIDs() {
+	policies := t.config.ActivePolicies()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if time.Since(t.queryIDsImported).Seconds() < t.config.PoliciesRefreshInterval && !pendingImports(policies) {
+		return
+	}
+	log.Debug("Importing query identifiers of policies")
+	if err := base.ImportQueryIDs(t.db, policies); err != nil {
+		log.Errorf("Keeping previous query identifiers: %v\n", err)
+	}
+	t.queryIDsImported = time.Now()
+}
+
+// pendingImports returns true when a policy has a query importing identifiers and has not
+// imported identifiers yet
+func pendingImports(policies []*base.Policy) bool {
+	for _, policy := range policies {
+		if policy.QueryIDsQuery != "" && policy.ImportedQueryIDs == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// assignPolicies attaches the first matching policy to each session, or the policy of
+// repeat offenders to sessions of repeat offenders in cool-down
+func (t *Terminator) assignPolicies(sessions []*base.Session) {
+	policies := t.config.ActivePolicies()
+	offenderPolicy := t.offenderPolicy(policies)
+	for _, session := range sessions {
+		if offenderPolicy != nil && t.offending(session) {
+			session.Policy = offenderPolicy
+			continue
+		}
+		session.Policy = base.MatchPolicy(policies, session)
+	}
+}
+
+// cancel returns true when the query of a session must be cancelled instead of terminating
+// the session, based on the policy hook decision, the session policy or the cancel option
+func (t *Terminator) cancel(session *base.Session) bool {
+	if session.Action != "" {
+		return session.Action == base.CancelDecision
+	}
+	if session.Policy != nil && session.Policy.Action != "" {
+		return session.Policy.Action == base.CancelAction
+	}
+	return t.config.Cancel
+}
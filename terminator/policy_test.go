@@ -0,0 +1,71 @@
+package terminator
+
+import (
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestPolicyTimeouts(t *testing.T) {
+	hour := 3600.0
+	zero := 0.0
+	config := &base.Config{
+		ActiveTimeout: 60,
+		IdleTimeout:   300,
+		Policies: []*base.Policy{
+			{Name: "reports", Users: []string{"report"}, ActiveTimeout: &hour, Action: base.CancelAction},
+			{Name: "etl", Users: []string{"etl"}, IdleTimeout: &zero, Action: base.TerminateAction},
+		},
+	}
+	if err := config.CompilePolicies(); err != nil {
+		t.Fatalf("got error %v; want no error", err)
+	}
+
+	tests := []struct {
+		name       string
+		session    *base.Session
+		wantActive float64
+		wantIdle   float64
+		wantCancel bool
+	}{
+		{"No policy", &base.Session{User: "test"}, 60, 300, false},
+		{"Active timeout and cancel", &base.Session{User: "report"}, 3600, 300, true},
+		{"Idle timeout disabled", &base.Session{User: "etl"}, 60, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: config}
+			terminator.assignPolicies([]*base.Session{tc.session})
+			active, idle, cancel := terminator.activeTimeout(tc.session), terminator.idleTimeout(tc.session), terminator.cancel(tc.session)
+			if active != tc.wantActive || idle != tc.wantIdle || cancel != tc.wantCancel {
+				t.Errorf("got %f, %f, %t; want %f, %f, %t", active, idle, cancel, tc.wantActive, tc.wantIdle, tc.wantCancel)
+			} else {
+				t.Logf("got %f, %f, %t; want %f, %f, %t", active, idle, cancel, tc.wantActive, tc.wantIdle, tc.wantCancel)
+			}
+		})
+	}
+}
+
+func TestPendingImports(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []*base.Policy
+		want     bool
+	}{
+		{"No import", []*base.Policy{{Name: "orm", QueryIDs: []int64{42}}}, false},
+		{"Not imported", []*base.Policy{{Name: "orm", QueryIDsQuery: "select 42"}}, true},
+		{"Imported", []*base.Policy{{Name: "orm", QueryIDsQuery: "select 42", ImportedQueryIDs: map[int64]bool{}}}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := pendingImports(tc.policies)
+			if got != tc.want {
+				t.Errorf("got %t; want %t", got, tc.want)
+			} else {
+				t.Logf("got %t; want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package terminator
+
+import (
+	"regexp"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// backupStartRegex matches queries starting a non-exclusive or exclusive backup
+var backupStartRegex = regexp.MustCompile(`(?i)\bpg_(start_backup|backup_start)\s*\(`)
+
+// protect removes protected sessions from offenders
+// Protected offenders are reported once to notifiers, and backups running longer than the
+// backup timeout are reported once as hung backups
+func (t *Terminator) protect(offenders []*base.Session) []*base.Session {
+	result, err := t.queryProtect(offenders)
+	base.Panic(err)
+	return result
+}
+
+// queryProtect removes protected sessions from offenders or returns an error when maintenance
+// operations can't be listed
+func (t *Terminator) queryProtect(offenders []*base.Session) (result []*base.Session, err error) {
+	if len(offenders) == 0 {
+		return offenders, nil
+	}
+	operations, err := t.db.QueryProgress()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range offenders {
+		session.Maintenance = operations[session.Pid]
+	}
+
+	var protected, hung []*base.Session
+	for _, session := range offenders {
+		if reason := t.protection(session); reason != "" {
+			log.Debugf("Session %d is protected: %s\n", session.Pid, reason)
+			session.Reason = reason
+			protected = append(protected, session)
+			if t.hungBackup(session) {
+				hung = append(hung, session)
+			}
+		} else {
+			result = append(result, session)
+		}
+	}
+	t.notify(t.unreportedProtected(protected), base.ProtectedEvent)
+	t.notify(t.unreportedHung(hung), base.HungBackupEvent)
+	return result, nil
+}
+
+// protection returns the reason why a session must not be terminated or an empty string
+func (t *Terminator) protection(session *base.Session) string {
+	if session.Annotation != nil && session.Annotation.Exempt && t.annotationCeiling(session.User) == 0 {
+		return "annotation=" + session.Annotation.Text
+	}
+	if backup := backupType(session); backup != "" {
+		return "backup=" + backup
+	}
+	if session.Maintenance != "" && (t.config.MaintenanceTimeout == 0 || session.StateDuration <= t.config.MaintenanceTimeout) {
+		return "maintenance=" + session.Maintenance
+	}
+	return ""
+}
+
+// unreportedProtected returns protected sessions that have not been reported during previous
+// iterations
+func (t *Terminator) unreportedProtected(sessions []*base.Session) (result []*base.Session) {
+	for _, session := range sessions {
+		if !t.protectedSessions[session.Pid] && !t.protectedCurrent[session.Pid] {
+			result = append(result, session)
+		}
+		t.protectedCurrent[session.Pid] = true
+	}
+	return result
+}
+
+// hungBackup returns true when a backup runs longer than the backup timeout
+func (t *Terminator) hungBackup(session *base.Session) bool {
+	return t.config.BackupTimeout != 0 && session.StateDuration > t.config.BackupTimeout && backupType(session) != ""
+}
+
+// unreportedHung returns hung backups that have not been reported during previous iterations
+func (t *Terminator) unreportedHung(sessions []*base.Session) (result []*base.Session) {
+	for _, session := range sessions {
+		if !t.hungSessions[session.Pid] && !t.hungCurrent[session.Pid] {
+			result = append(result, session)
+		}
+		t.hungCurrent[session.Pid] = true
+	}
+	return result
+}
+
+// rotateProtected forgets about sessions that have not been protected offenders or hung
+// backups during the current iteration, so they are reported again if they become protected
+// offenders or hung backups later
+func (t *Terminator) rotateProtected() {
+	t.protectedSessions = t.protectedCurrent
+	t.protectedCurrent = make(map[int64]bool)
+	t.hungSessions = t.hungCurrent
+	t.hungCurrent = make(map[int64]bool)
+}
+
+// backupType returns the kind of backup performed by a session or an empty string
+func backupType(session *base.Session) string {
+	switch {
+	case session.ApplicationName == "pg_dump" || session.ApplicationName == "pg_dumpall":
+		return session.ApplicationName
+	case session.Maintenance == "basebackup" || (session.BackendType == "walsender" && session.ApplicationName == "pg_basebackup"):
+		return "pg_basebackup"
+	case backupStartRegex.MatchString(session.Query):
+		return "pg_backup_start"
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestProtection(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *base.Config
+		session *base.Session
+		want    string
+	}{
+		{
+			"Regular session",
+			&base.Config{},
+			&base.Session{StateDuration: 600},
+			"",
+		},
+		{
+			"Maintenance without timeout",
+			&base.Config{},
+			&base.Session{Maintenance: "vacuum", StateDuration: 600},
+			"maintenance=vacuum",
+		},
+		{
+			"Maintenance under timeout",
+			&base.Config{MaintenanceTimeout: 3600},
+			&base.Session{Maintenance: "create_index", StateDuration: 600},
+			"maintenance=create_index",
+		},
+		{
+			"Maintenance over timeout",
+			&base.Config{MaintenanceTimeout: 300},
+			&base.Session{Maintenance: "create_index", StateDuration: 600},
+			"",
+		},
+		{
+			"Backup without timeout",
+			&base.Config{},
+			&base.Session{ApplicationName: "pg_dump", StateDuration: 600},
+			"backup=pg_dump",
+		},
+		{
+			"Base backup over timeout",
+			&base.Config{BackupTimeout: 300},
+			&base.Session{Maintenance: "basebackup", StateDuration: 600},
+			"backup=pg_basebackup",
+		},
+		{
+			"Backup over timeout",
+			&base.Config{BackupTimeout: 300},
+			&base.Session{ApplicationName: "pg_dump", StateDuration: 600},
+			"backup=pg_dump",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: tc.config}
+			got := terminator.protection(tc.session)
+			if got != tc.want {
+				t.Errorf("got '%s'; want '%s'", got, tc.want)
+			} else {
+				t.Logf("got '%s'; want '%s'", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnreportedProtected(t *testing.T) {
+	terminator := &Terminator{protectedSessions: make(map[int64]bool), protectedCurrent: make(map[int64]bool)}
+
+	first := []*base.Session{{Pid: 1, User: "test"}}
+	if got := ListUsers(terminator.unreportedProtected(first)); !reflect.DeepEqual(got, []string{"test"}) {
+		t.Errorf("got %+v; want [test]", got)
+	}
+	if got := ListUsers(terminator.unreportedProtected(first)); got != nil {
+		t.Errorf("got %+v; want []", got)
+	}
+	terminator.rotateProtected()
+
+	second := []*base.Session{{Pid: 1, User: "test"}, {Pid: 2, User: "test_1"}}
+	if got := ListUsers(terminator.unreportedProtected(second)); !reflect.DeepEqual(got, []string{"test_1"}) {
+		t.Errorf("got %+v; want [test_1]", got)
+	}
+	terminator.rotateProtected()
+	terminator.rotateProtected()
+
+	if got := ListUsers(terminator.unreportedProtected(first)); !reflect.DeepEqual(got, []string{"test"}) {
+		t.Errorf("got %+v; want [test]", got)
+	}
+}
+
+func TestBackupType(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    string
+	}{
+		{"Regular session", &base.Session{ApplicationName: "psql", Query: "select 1"}, ""},
+		{"pg_dump", &base.Session{ApplicationName: "pg_dump"}, "pg_dump"},
+		{"pg_dumpall", &base.Session{ApplicationName: "pg_dumpall"}, "pg_dumpall"},
+		{"pg_basebackup walsender", &base.Session{BackendType: "walsender", ApplicationName: "pg_basebackup"}, "pg_basebackup"},
+		{"Base backup in progress", &base.Session{Maintenance: "basebackup"}, "pg_basebackup"},
+		{"Non-exclusive backup", &base.Session{State: "idle", Query: "SELECT pg_backup_start('label', true);"}, "pg_backup_start"},
+		{"Exclusive backup", &base.Session{State: "idle", Query: "select pg_start_backup('label')"}, "pg_backup_start"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := backupType(tc.session)
+			if got != tc.want {
+				t.Errorf("got '%s'; want '%s'", got, tc.want)
+			} else {
+				t.Logf("got '%s'; want '%s'", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnreportedHung(t *testing.T) {
+	terminator := &Terminator{
+		config:       &base.Config{BackupTimeout: 300},
+		hungSessions: make(map[int64]bool),
+		hungCurrent:  make(map[int64]bool),
+	}
+	backups := []*base.Session{
+		{Pid: 1, User: "hung", ApplicationName: "pg_dump", StateDuration: 600},
+		{Pid: 2, User: "running", ApplicationName: "pg_dump", StateDuration: 60},
+		{Pid: 3, User: "regular", StateDuration: 600},
+	}
+	var hung []*base.Session
+	for _, session := range backups {
+		if terminator.hungBackup(session) {
+			hung = append(hung, session)
+		}
+	}
+
+	if got := ListUsers(terminator.unreportedHung(hung)); !reflect.DeepEqual(got, []string{"hung"}) {
+		t.Errorf("got %+v; want [hung]", got)
+	}
+	terminator.rotateProtected()
+	if got := ListUsers(terminator.unreportedHung(hung)); got != nil {
+		t.Errorf("got %+v; want no hung backup reported twice", got)
+	}
+}
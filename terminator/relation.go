@@ -0,0 +1,67 @@
+package terminator
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// relations cancels or terminates sessions holding or waiting for locks on relations
+// Relation identifiers are resolved with a connection to each database having relation locks,
+// databases that can't be reached are skipped
+func (t *Terminator) relations(sessions []*base.Session) {
+	if t.config.Relations == nil && t.config.RelationsRegexCompiled == nil {
+		return
+	}
+	var locks []*base.RelationLock
+	for _, database := range t.db.LockedDatabases() {
+		log.Debugf("Resolving relation locks in database %s\n", database)
+		db, err := t.database(database)
+		if err != nil {
+			log.Errorf("Cannot connect to database %s to resolve relation locks: %v\n", database, err)
+			continue
+		}
+		if db == nil {
+			continue
+		}
+		databaseLocks, err := db.RelationLocks()
+		if err != nil {
+			log.Errorf("Cannot resolve relation locks in database %s: %v\n", database, err)
+			continue
+		}
+		locks = append(locks, databaseLocks...)
+	}
+	targets := t.protect(t.filter(relationSessions(sessions, matchingLocks(locks, t.config.Relations, t.config.RelationsRegexCompiled))))
+	t.kill(targets)
+	t.notify(targets, base.RelationEvent)
+}
+
+// matchingLocks returns locks on relations included in the list of relations or matching the regex
+// Relations can be schema qualified or not
+func matchingLocks(locks []*base.RelationLock, relations []string, regex *regexp.Regexp) (result []*base.RelationLock) {
+	for _, lock := range locks {
+		if base.InSlice(lock.Relation, relations) || base.InSlice(lock.Name(), relations) || (regex != nil && regex.MatchString(lock.Name())) {
+			result = append(result, lock)
+		}
+	}
+	return result
+}
+
+// relationSessions returns sessions holding or waiting for locks with relations and lock modes as reason
+func relationSessions(sessions []*base.Session, locks []*base.RelationLock) (result []*base.Session) {
+	for _, session := range sessions {
+		var descriptions []string
+		for _, lock := range locks {
+			if lock.Pid == session.Pid && lock.Db == session.Db {
+				descriptions = append(descriptions, lock.String())
+			}
+		}
+		if descriptions != nil {
+			session.Reason = "locks=" + strings.Join(descriptions, ",")
+			result = append(result, session)
+		}
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package terminator
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestMatchingLocks(t *testing.T) {
+
+	locks := []*base.RelationLock{
+		{Pid: 1, Schema: "public", Relation: "test"},
+		{Pid: 2, Schema: "public", Relation: "test_1"},
+		{Pid: 3, Schema: "app", Relation: "test"},
+	}
+
+	tests := []struct {
+		name      string
+		relations []string
+		regex     string
+		want      []int64
+	}{
+		{"No relation", nil, "", nil},
+		{"Unqualified relation", []string{"test"}, "", []int64{1, 3}},
+		{"Qualified relation", []string{"app.test"}, "", []int64{3}},
+		{"Regex", nil, "^public\\.", []int64{1, 2}},
+		{"Relation and regex", []string{"app.test"}, "_1$", []int64{2, 3}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var regex *regexp.Regexp
+			if tc.regex != "" {
+				regex = regexp.MustCompile(tc.regex)
+			}
+			var got []int64
+			for _, lock := range matchingLocks(locks, tc.relations, regex) {
+				got = append(got, lock.Pid)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRelationSessions(t *testing.T) {
+
+	sessions := []*base.Session{
+		{Pid: 1, Db: "test"},
+		{Pid: 2, Db: "test"},
+	}
+	locks := []*base.RelationLock{
+		{Pid: 1, Db: "test", Schema: "public", Relation: "test", Mode: "AccessShareLock", Granted: true},
+		{Pid: 1, Db: "test", Schema: "public", Relation: "test_1", Mode: "AccessExclusiveLock", Granted: false},
+	}
+
+	got := relationSessions(sessions, locks)
+	if len(got) != 1 || got[0].Pid != 1 {
+		t.Fatalf("got %+v; want session with pid 1", got)
+	}
+	want := "locks=public.test:AccessShareLock,public.test_1:AccessExclusiveLock:waiting"
+	if got[0].Reason != want {
+		t.Errorf("got %s; want %s", got[0].Reason, want)
+	} else {
+		t.Logf("got %s; want %s", got[0].Reason, want)
+	}
+}
+
+func TestRelationsProtected(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    bool
+	}{
+		{"Session of a client is terminated", &base.Session{Pid: 1, Db: "app", State: "active", ApplicationName: "psql"}, true},
+		{"Session of a backup is protected", &base.Session{Pid: 1, Db: "app", State: "active", ApplicationName: "pg_dump"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator, fake := newFakeTerminator(t, &base.Config{Relations: []string{"t"}},
+				fakeResponse{match: "select distinct d.datname", rows: [][]driver.Value{{"app"}}},
+				fakeResponse{match: "select current_database();", rows: [][]driver.Value{{"app"}}},
+				fakeResponse{match: "l.granted as granted", rows: [][]driver.Value{{int64(1), "app", "public", "t", "AccessShareLock", true}}},
+				fakeResponse{match: "pg_terminate_backend"},
+			)
+			terminator.relations([]*base.Session{tc.session})
+			got := fake.signaled()
+			if got != tc.want {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
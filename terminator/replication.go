@@ -0,0 +1,67 @@
+package terminator
+
+import (
+	"fmt"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+// replication terminates lagging walsenders and reports inactive slots retaining too much WAL
+func (t *Terminator) replication() {
+	if t.config.ReplicationLagBytes != 0 || t.config.ReplicationLagTime != 0 {
+		walsenders := t.protect(t.filterUsers(laggingWalsenders(t.db.Walsenders(), t.config.ReplicationLagBytes, t.config.ReplicationLagTime)))
+		t.db.TerminateSessions(walsenders)
+		t.notify(walsenders, base.WalsenderEvent)
+	}
+
+	if t.config.SlotRetainedBytes != 0 {
+		slots := t.unreportedSlots(retainingSlots(t.db.Slots(), t.config.SlotRetainedBytes))
+		if t.config.DropSlots {
+			t.db.DropSlots(slots)
+		}
+		for _, slot := range slots {
+			t.sessions <- slot.Session()
+		}
+	}
+}
+
+// unreportedSlots returns slots that have not been reported yet and forgets about slots
+// that are not retaining too much WAL anymore, so they are reported again when they do
+func (t *Terminator) unreportedSlots(slots []*base.Slot) (result []*base.Slot) {
+	retaining := make(map[string]bool)
+	for _, slot := range slots {
+		retaining[slot.Name] = true
+		if !t.reportedSlots[slot.Name] {
+			t.reportedSlots[slot.Name] = true
+			result = append(result, slot)
+		}
+	}
+	for name := range t.reportedSlots {
+		if !retaining[name] || t.config.DropSlots {
+			delete(t.reportedSlots, name)
+		}
+	}
+	return result
+}
+
+// laggingWalsenders returns walsenders with lag exceeding bytes or seconds
+// A zero threshold is ignored
+func laggingWalsenders(walsenders []*base.Walsender, bytes int64, seconds float64) (result []*base.Session) {
+	for _, walsender := range walsenders {
+		if (bytes != 0 && walsender.LagBytes > bytes) || (seconds != 0 && walsender.LagTime > seconds) {
+			walsender.Session.Reason = fmt.Sprintf("lag_bytes=%d lag_time=%f", walsender.LagBytes, walsender.LagTime)
+			result = append(result, walsender.Session)
+		}
+	}
+	return result
+}
+
+// retainingSlots returns inactive slots retaining more than bytes of WAL
+func retainingSlots(slots []*base.Slot, bytes int64) (result []*base.Slot) {
+	for _, slot := range slots {
+		if !slot.Active && slot.RetainedBytes > bytes {
+			result = append(result, slot)
+		}
+	}
+	return result
+}
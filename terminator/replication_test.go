@@ -0,0 +1,74 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestLaggingWalsenders(t *testing.T) {
+
+	walsenders := []*base.Walsender{
+		{Session: &base.Session{User: "test"}, LagBytes: 0, LagTime: 0},
+		{Session: &base.Session{User: "test_1"}, LagBytes: 1024, LagTime: 1},
+		{Session: &base.Session{User: "test_2"}, LagBytes: 10, LagTime: 60},
+	}
+
+	tests := []struct {
+		name    string
+		bytes   int64
+		seconds float64
+		want    []string
+	}{
+		{"No threshold", 0, 0, nil},
+		{"Bytes threshold", 100, 0, []string{"test_1"}},
+		{"Time threshold", 0, 30, []string{"test_2"}},
+		{"Bytes and time thresholds", 100, 30, []string{"test_1", "test_2"}},
+		{"Thresholds not reached", 2048, 120, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(laggingWalsenders(walsenders, tc.bytes, tc.seconds))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetainingSlots(t *testing.T) {
+
+	slots := []*base.Slot{
+		{Name: "active", Active: true, RetainedBytes: 4096},
+		{Name: "inactive", Active: false, RetainedBytes: 4096},
+		{Name: "small", Active: false, RetainedBytes: 10},
+	}
+
+	tests := []struct {
+		name  string
+		bytes int64
+		want  []string
+	}{
+		{"Inactive slots retaining WAL", 100, []string{"inactive"}},
+		{"Threshold not reached", 8192, nil},
+		{"Small threshold", 1, []string{"inactive", "small"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, slot := range retainingSlots(slots, tc.bytes) {
+				got = append(got, slot.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package terminator
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// stateChangeTolerance is the maximum difference between state change times computed at
+// different iterations for the same state
+const stateChangeTolerance = 100 * time.Millisecond
+
+// resourceUsage tracks resources used by a backend since its last state change
+type resourceUsage struct {
+	state       string
+	stateChange time.Time
+	baseline    *base.Resources
+	last        *base.Resources
+	sampled     time.Time
+}
+
+// readResources attaches resources used by backends since their last state change to
+// sessions when resource limits are configured and PostgreSQL processes are local
+// Counters are not sampled when the state changes, so the baseline of a new state is
+// estimated at the state change time between the last sample and the current one. When the
+// backend has just been discovered, the baseline is estimated between the backend start and
+// the current sample, or is the current sample when the backend start is unknown. A new state
+// is detected when the state or the state change time differ from the previous sample, with
+// a tolerance for clock jitter.
+// Storage usage is left out with a warning logged once when it can't be read because of
+// permissions.
+func (t *Terminator) readResources(sessions []*base.Session, now time.Time) {
+	if !t.config.HasResourceLimits() || !t.localProcesses() {
+		return
+	}
+	seen := make(map[int64]bool)
+	for _, session := range sessions {
+		resources, err := base.ReadResources(t.procDir, session.Pid)
+		if err != nil {
+			log.Debugf("Cannot read resources of session %d: %v\n", session.Pid, err)
+			continue
+		}
+		if !t.ioUnreadable {
+			resources.ReadBytes, resources.WriteBytes, err = base.ReadIO(t.procDir, session.Pid)
+			if os.IsPermission(err) {
+				log.Warnf("Ignoring read-bytes-limit because storage usage of processes is not readable: %v\n", err)
+				t.ioUnreadable = true
+			} else if err != nil {
+				log.Debugf("Cannot read storage usage of session %d: %v\n", session.Pid, err)
+			}
+		}
+		seen[session.Pid] = true
+		stateChange := now.Add(-time.Duration(session.StateDuration*1000) * time.Millisecond)
+		usage, ok := t.resourceUsages[session.Pid]
+		switch {
+		case !ok:
+			usage = &resourceUsage{baseline: resources}
+			if !session.BackendStart.IsZero() {
+				usage.baseline = resourcesAt(stateChange, session.BackendStart, &base.Resources{}, now, resources)
+			}
+			t.resourceUsages[session.Pid] = usage
+		case session.State != usage.state || stateChange.Sub(usage.stateChange) > stateChangeTolerance:
+			usage.baseline = resourcesAt(stateChange, usage.sampled, usage.last, now, resources)
+		}
+		usage.state = session.State
+		usage.stateChange = stateChange
+		usage.last = resources
+		usage.sampled = now
+		session.Resources = &base.Resources{
+			RSS:        resources.RSS,
+			CPU:        resources.CPU - usage.baseline.CPU,
+			ReadBytes:  resources.ReadBytes - usage.baseline.ReadBytes,
+			WriteBytes: resources.WriteBytes - usage.baseline.WriteBytes,
+		}
+	}
+	for pid := range t.resourceUsages {
+		if !seen[pid] {
+			delete(t.resourceUsages, pid)
+		}
+	}
+}
+
+// resourcesAt estimates counters of resources at a time between a previous and a current
+// sample, assuming resources have been used evenly between samples
+func resourcesAt(at time.Time, previousTime time.Time, previous *base.Resources, currentTime time.Time, current *base.Resources) *base.Resources {
+	fraction := 1.0
+	if elapsed := currentTime.Sub(previousTime); elapsed > 0 {
+		fraction = math.Max(0, math.Min(1, float64(at.Sub(previousTime))/float64(elapsed)))
+	}
+	return &base.Resources{
+		CPU:        previous.CPU + (current.CPU-previous.CPU)*fraction,
+		ReadBytes:  previous.ReadBytes + int64(float64(current.ReadBytes-previous.ReadBytes)*fraction),
+		WriteBytes: previous.WriteBytes + int64(float64(current.WriteBytes-previous.WriteBytes)*fraction),
+	}
+}
+
+// localProcesses returns true when the backend of pgterminate is a local process, meaning
+// PostgreSQL runs on the same host
+// The check is done once and a warning is logged when processes are not local
+func (t *Terminator) localProcesses() bool {
+	if !t.processesChecked {
+		t.processesLocal = base.IsPostgres(t.procDir, t.db.BackendPid())
+		if !t.processesLocal {
+			log.Warn("Ignoring resource limits because PostgreSQL processes are not local")
+		}
+		t.processesChecked = true
+	}
+	return t.processesLocal
+}
+
+// resources cancels active queries using too many resources
+// Protected sessions, like backups, are left alone
+func (t *Terminator) resources(sessions []*base.Session) {
+	if !t.config.HasResourceLimits() || !t.localProcesses() {
+		return
+	}
+	readBytesLimit := t.config.ReadBytesLimit
+	if t.ioUnreadable {
+		readBytesLimit = 0
+	}
+	targets := t.protect(t.filter(overusingSessions(sessions, t.config.RSSLimit, t.config.CPULimit, readBytesLimit)))
+	t.db.CancelSessions(targets)
+	t.notify(targets, base.ResourceEvent)
+}
+
+// overusingSessions returns active sessions using more than rss bytes of resident memory, cpu
+// seconds of CPU time or readBytes bytes read from storage with the resource as reason
+// A zero limit is ignored
+func overusingSessions(sessions []*base.Session, rss int64, cpu float64, readBytes int64) (result []*base.Session) {
+	for _, session := range sessions {
+		if session.State != "active" || session.Resources == nil {
+			continue
+		}
+		var reason string
+		switch {
+		case rss != 0 && session.Resources.RSS > rss:
+			reason = fmt.Sprintf("rss=%d", session.Resources.RSS)
+		case cpu != 0 && session.Resources.CPU > cpu:
+			reason = fmt.Sprintf("cpu=%f", session.Resources.CPU)
+		case readBytes != 0 && session.Resources.ReadBytes > readBytes:
+			reason = fmt.Sprintf("read_bytes=%d", session.Resources.ReadBytes)
+		default:
+			continue
+		}
+		session.Reason = reason
+		result = append(result, session)
+	}
+	return result
+}
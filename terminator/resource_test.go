@@ -0,0 +1,147 @@
+package terminator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestOverusingSessions(t *testing.T) {
+	sessions := []*base.Session{
+		{User: "memory", State: "active", Resources: &base.Resources{RSS: 5 << 30}},
+		{User: "cpu", State: "active", Resources: &base.Resources{CPU: 700}},
+		{User: "read", State: "active", Resources: &base.Resources{ReadBytes: 60 << 30}},
+		{User: "idle", State: "idle", Resources: &base.Resources{RSS: 5 << 30}},
+		{User: "unknown", State: "active"},
+		{User: "small", State: "active", Resources: &base.Resources{RSS: 1 << 30, CPU: 1, ReadBytes: 1}},
+	}
+
+	tests := []struct {
+		name      string
+		rss       int64
+		cpu       float64
+		readBytes int64
+		want      []string
+	}{
+		{"No limit", 0, 0, 0, nil},
+		{"RSS", 4 << 30, 0, 0, []string{"memory"}},
+		{"CPU", 0, 600, 0, []string{"cpu"}},
+		{"Read bytes", 0, 0, 50 << 30, []string{"read"}},
+		{"All limits", 4 << 30, 600, 50 << 30, []string{"memory", "cpu", "read"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListUsers(overusingSessions(sessions, tc.rss, tc.cpu, tc.readBytes))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadResources(t *testing.T) {
+	procDir := t.TempDir()
+	writeStat := func(utime string) {
+		dir := filepath.Join(procDir, "42")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			"status": "RssAnon:\t1 kB\n",
+			"stat":   "42 (postgres) S 1 1 1 0 -1 0 0 0 0 0 " + utime + " 0 0 0\n",
+			"io":     "read_bytes: 0\nwrite_bytes: 0\n",
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	terminator := &Terminator{
+		config:           &base.Config{CPULimit: 1},
+		procDir:          procDir,
+		processesChecked: true,
+		processesLocal:   true,
+		resourceUsages:   make(map[int64]*resourceUsage),
+	}
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	backendStart := now.Add(-200 * time.Second)
+	steps := []struct {
+		name    string
+		utime   string
+		elapsed time.Duration
+		session *base.Session
+		want    float64
+	}{
+		{"Discovered", "1000", 0, &base.Session{Pid: 42, State: "active", StateDuration: 100, BackendStart: backendStart}, 5},
+		{"Same query", "1500", time.Second, &base.Session{Pid: 42, State: "active", StateDuration: 101, BackendStart: backendStart}, 10},
+		{"Idle", "1600", 2 * time.Second, &base.Session{Pid: 42, State: "idle", StateDuration: 0.5, BackendStart: backendStart}, 0.5},
+		{"New query", "1800", 3 * time.Second, &base.Session{Pid: 42, State: "active", StateDuration: 0.5, BackendStart: backendStart}, 1},
+	}
+
+	for _, step := range steps {
+		writeStat(step.utime)
+		terminator.readResources([]*base.Session{step.session}, now.Add(step.elapsed))
+		if step.session.Resources == nil {
+			t.Fatalf("%s: got no resources; want resources", step.name)
+		}
+		got := step.session.Resources.CPU
+		if got != step.want {
+			t.Errorf("%s: got %f; want %f", step.name, got, step.want)
+		} else {
+			t.Logf("%s: got %f; want %f", step.name, got, step.want)
+		}
+	}
+}
+
+func TestResourcesAt(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := &base.Resources{CPU: 10, ReadBytes: 100, WriteBytes: 10}
+	current := &base.Resources{CPU: 20, ReadBytes: 300, WriteBytes: 10}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want *base.Resources
+	}{
+		{"Previous sample", start, previous},
+		{"Between samples", start.Add(time.Second), &base.Resources{CPU: 15, ReadBytes: 200, WriteBytes: 10}},
+		{"Current sample", start.Add(2 * time.Second), &base.Resources{CPU: 20, ReadBytes: 300, WriteBytes: 10}},
+		{"Before previous sample", start.Add(-time.Second), previous},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := resourcesAt(tc.at, start, previous, start.Add(2*time.Second), current)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResourcesIOUnreadable(t *testing.T) {
+	sessions := []*base.Session{
+		{Pid: 1, State: "active", Resources: &base.Resources{ReadBytes: 100}},
+	}
+	terminator := &Terminator{
+		config:           &base.Config{ReadBytesLimit: 10},
+		processesChecked: true,
+		processesLocal:   true,
+		ioUnreadable:     true,
+	}
+
+	// No session is over a limit, so the database is not reached
+	terminator.resources(sessions)
+}
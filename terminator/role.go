@@ -0,0 +1,60 @@
+package terminator
+
+import (
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// refreshRoles fetches role attributes and memberships when role filters are configured and
+// the refresh interval is elapsed
+func (t *Terminator) refreshRoles() {
+	if !t.config.HasRoleFilters() {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if time.Since(t.rolesRefreshed).Seconds() < t.config.RolesRefreshInterval {
+		return
+	}
+	log.Debug("Refreshing role attributes and memberships")
+	t.roles = t.db.Roles()
+	t.rolesRefreshed = time.Now()
+}
+
+// filterRoles excludes sessions based on role attributes and memberships
+// Sessions of unknown roles are excluded until roles are refreshed
+func (t *Terminator) filterRoles(sessions []*base.Session) (filtered []*base.Session) {
+	if !t.config.HasRoleFilters() {
+		return sessions
+	}
+	for _, session := range sessions {
+		role, ok := t.roles[session.User]
+		if !ok {
+			log.Debugf("Ignoring session %d of unknown role %s\n", session.Pid, session.User)
+			continue
+		}
+		if t.config.ExcludeSuperusers && role.Superuser {
+			continue
+		}
+		if t.config.ExcludeReplicationRoles && role.Replication {
+			continue
+		}
+		if t.config.IncludeMembersOf != nil && !isMember(role, t.config.IncludeMembersOf) {
+			continue
+		}
+		filtered = append(filtered, session)
+	}
+	return filtered
+}
+
+// isMember returns true when the role is one of the groups or a member of one of them
+func isMember(role *base.Role, groups []string) bool {
+	for _, group := range groups {
+		if role.Name == group || base.InSlice(group, role.MemberOf) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestFilterRoles(t *testing.T) {
+
+	sessions := []*base.Session{
+		{User: "postgres"},
+		{User: "replicator"},
+		{User: "alice"},
+		{User: "bob"},
+		{User: "unknown"},
+	}
+	roles := map[string]*base.Role{
+		"postgres":   {Name: "postgres", Superuser: true},
+		"replicator": {Name: "replicator", Replication: true},
+		"alice":      {Name: "alice", MemberOf: []string{"analysts"}},
+		"bob":        {Name: "bob", MemberOf: []string{"interns", "analysts"}},
+	}
+
+	tests := []struct {
+		name   string
+		config *base.Config
+		want   []string
+	}{
+		{
+			"No filter",
+			&base.Config{},
+			[]string{"postgres", "replicator", "alice", "bob", "unknown"},
+		},
+		{
+			"Exclude superusers",
+			&base.Config{ExcludeSuperusers: true},
+			[]string{"replicator", "alice", "bob"},
+		},
+		{
+			"Exclude replication roles",
+			&base.Config{ExcludeReplicationRoles: true},
+			[]string{"postgres", "alice", "bob"},
+		},
+		{
+			"Include members of a group",
+			&base.Config{IncludeMembersOf: []string{"interns"}},
+			[]string{"bob"},
+		},
+		{
+			"Include members of multiple groups",
+			&base.Config{IncludeMembersOf: []string{"interns", "analysts"}},
+			[]string{"alice", "bob"},
+		},
+		{
+			"Include group itself",
+			&base.Config{IncludeMembersOf: []string{"postgres"}},
+			[]string{"postgres"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: tc.config, roles: roles}
+			got := ListUsers(terminator.filterRoles(sessions))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
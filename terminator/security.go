@@ -0,0 +1,32 @@
+package terminator
+
+import (
+	"github.com/jouir/pgterminate/base"
+)
+
+// security terminates sessions connected over the network without meeting the security
+// requirement
+func (t *Terminator) security() {
+	requirement := t.config.SecurityRequirement()
+	if !requirement.Enabled() {
+		return
+	}
+	targets := t.protect(t.filter(insecureSessions(t.db.Encryptions(), requirement)))
+	t.db.TerminateSessions(targets)
+	t.notify(targets, base.SecurityEvent)
+}
+
+// insecureSessions returns sessions connected over the network not meeting the requirement
+// with the violation as reason
+func insecureSessions(encryptions []*base.Encryption, requirement *base.SecurityRequirement) (result []*base.Session) {
+	for _, encryption := range encryptions {
+		if encryption.Local {
+			continue
+		}
+		if violation := requirement.Violation(encryption); violation != "" {
+			encryption.Session.Reason = violation
+			result = append(result, encryption.Session)
+		}
+	}
+	return result
+}
@@ -0,0 +1,29 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestInsecureSessions(t *testing.T) {
+	encryptions := []*base.Encryption{
+		{Session: &base.Session{User: "local"}, Local: true},
+		{Session: &base.Session{User: "plain"}},
+		{Session: &base.Session{User: "old"}, SSL: true, Version: "TLSv1"},
+		{Session: &base.Session{User: "secure"}, SSL: true, Version: "TLSv1.3"},
+	}
+	requirement := &base.SecurityRequirement{MinProtocol: "TLSv1.2"}
+
+	got := insecureSessions(encryptions, requirement)
+	want := []*base.Session{
+		{User: "plain", Reason: "ssl=off"},
+		{User: "old", Reason: "protocol=TLSv1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", ListUsers(got), ListUsers(want))
+	} else {
+		t.Logf("got %+v; want %+v", ListUsers(got), ListUsers(want))
+	}
+}
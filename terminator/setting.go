@@ -0,0 +1,64 @@
+package terminator
+
+import (
+	"time"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// refreshSettings fetches settings defined on roles and databases when enabled and the
+// refresh interval is elapsed
+// Invalid settings are reported and ignored
+func (t *Terminator) refreshSettings() {
+	if !t.config.DatabaseSettings {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if time.Since(t.settingsRefreshed).Seconds() < t.config.SettingsRefreshInterval {
+		return
+	}
+	log.Debug("Refreshing settings defined on roles and databases")
+	settings, errs := t.db.Settings()
+	for _, err := range errs {
+		log.Warnf("Ignoring setting: %v\n", err)
+	}
+	t.settings = settings
+	t.settingsRefreshed = time.Now()
+}
+
+// activeTimeout returns the active timeout of a session
+// Policies override configuration, settings override policies and annotations override
+// settings
+func (t *Terminator) activeTimeout(session *base.Session) float64 {
+	timeout, _ := t.timeouts()
+	if session.Policy != nil && session.Policy.ActiveTimeout != nil {
+		timeout = *session.Policy.ActiveTimeout
+	}
+	if value, found := t.settings.Lookup(session.User, session.Db, base.ActiveTimeoutSetting); found {
+		timeout = value
+	}
+	if session.Annotation != nil {
+		timeout = t.annotationTimeout(session, session.Annotation.ActiveTimeout, timeout)
+	}
+	return timeout
+}
+
+// idleTimeout returns the idle timeout of a session
+// Transaction timeouts override the idle timeout, policies override configuration, settings
+// override policies and annotations override settings
+func (t *Terminator) idleTimeout(session *base.Session) float64 {
+	_, timeout := t.timeouts()
+	timeout = t.transactionTimeout(session, timeout)
+	if session.Policy != nil && session.Policy.IdleTimeout != nil {
+		timeout = *session.Policy.IdleTimeout
+	}
+	if value, found := t.settings.Lookup(session.User, session.Db, base.IdleTimeoutSetting); found {
+		timeout = value
+	}
+	if session.Annotation != nil {
+		timeout = t.annotationTimeout(session, session.Annotation.IdleTimeout, timeout)
+	}
+	return timeout
+}
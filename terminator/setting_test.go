@@ -0,0 +1,57 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestSettingTimeouts(t *testing.T) {
+
+	sessions := []*base.Session{
+		{User: "test", Db: "test", State: "active", StateDuration: 120},
+		{User: "analyst", Db: "test", State: "active", StateDuration: 120},
+		{User: "test", Db: "reporting", State: "active", StateDuration: 120},
+		{User: "analyst", Db: "test", State: "idle", StateDuration: 120},
+		{User: "test", Db: "test", State: "idle", StateDuration: 120},
+	}
+	settings := base.Settings{
+		{Role: "analyst", Name: base.ActiveTimeoutSetting, Value: 3600},
+		{Db: "reporting", Name: base.ActiveTimeoutSetting, Value: 0},
+		{Role: "analyst", Name: base.IdleTimeoutSetting, Value: 60},
+	}
+
+	tests := []struct {
+		name        string
+		config      *base.Config
+		wantActives []string
+		wantIdles   []string
+	}{
+		{
+			"Settings override timeouts",
+			&base.Config{ActiveTimeout: 60, IdleTimeout: 300},
+			[]string{"test"},
+			[]string{"analyst"},
+		},
+		{
+			"Settings without timeouts",
+			&base.Config{},
+			nil,
+			[]string{"analyst"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: tc.config, settings: settings}
+			actives := ListUsers(activeSessions(sessions, terminator.activeTimeout))
+			idles := ListUsers(idleSessions(sessions, terminator.idleTimeout))
+			if !reflect.DeepEqual(actives, tc.wantActives) || !reflect.DeepEqual(idles, tc.wantIdles) {
+				t.Errorf("got %+v, %+v; want %+v, %+v", actives, idles, tc.wantActives, tc.wantIdles)
+			} else {
+				t.Logf("got %+v, %+v; want %+v, %+v", actives, idles, tc.wantActives, tc.wantIdles)
+			}
+		})
+	}
+}
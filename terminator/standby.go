@@ -0,0 +1,73 @@
+package terminator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jouir/pgterminate/base"
+	"github.com/jouir/pgterminate/log"
+)
+
+// detectRecovery looks if the instance is a standby and logs when it changes
+func (t *Terminator) detectRecovery() {
+	inRecovery := t.db.InRecovery()
+	if inRecovery != t.inRecovery {
+		if inRecovery {
+			log.Info("Instance is in recovery, enabling standby rules")
+		} else {
+			log.Info("Instance is not in recovery, disabling standby rules")
+		}
+		t.inRecovery = inRecovery
+	}
+}
+
+// timeouts returns active and idle timeouts to apply depending on the instance role
+// Standby timeouts override primary timeouts when the instance is in recovery
+func (t *Terminator) timeouts() (activeTimeout float64, idleTimeout float64) {
+	activeTimeout, idleTimeout = t.config.ActiveTimeout, t.config.IdleTimeout
+	if t.inRecovery {
+		if t.config.StandbyActiveTimeout != 0 {
+			activeTimeout = t.config.StandbyActiveTimeout
+		}
+		if t.config.StandbyIdleTimeout != 0 {
+			idleTimeout = t.config.StandbyIdleTimeout
+		}
+	}
+	return activeTimeout, idleTimeout
+}
+
+// standby cancels the longest running query when replay lag exceeds the threshold
+// One query is cancelled per iteration until lag recovers
+func (t *Terminator) standby(sessions []*base.Session) {
+	if !t.inRecovery || t.config.StandbyReplayLag == 0 {
+		return
+	}
+	lag := t.db.ReplayLag()
+	if lag <= t.config.StandbyReplayLag {
+		return
+	}
+	log.Debugf("Replay lag of %f seconds exceeds %f seconds\n", lag, t.config.StandbyReplayLag)
+	var actives []*base.Session
+	for _, session := range sessions {
+		if session.State == "active" {
+			actives = append(actives, session)
+		}
+	}
+	candidates := t.protect(t.filter(oldestSessions(actives)))
+	if len(candidates) > 0 {
+		oldest := candidates[:1]
+		oldest[0].Reason = fmt.Sprintf("replay_lag=%f", lag)
+		t.db.CancelSessions(oldest)
+		t.notify(oldest, base.StandbyEvent)
+	}
+}
+
+// oldestSessions returns sessions sorted by state duration, oldest first
+func oldestSessions(sessions []*base.Session) []*base.Session {
+	sorted := make([]*base.Session, len(sessions))
+	copy(sorted, sessions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StateDuration > sorted[j].StateDuration
+	})
+	return sorted
+}
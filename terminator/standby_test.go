@@ -0,0 +1,96 @@
+package terminator
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestTimeouts(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     *base.Config
+		inRecovery bool
+		wantActive float64
+		wantIdle   float64
+	}{
+		{
+			"Primary",
+			&base.Config{ActiveTimeout: 10, IdleTimeout: 300, StandbyActiveTimeout: 60, StandbyIdleTimeout: 600},
+			false,
+			10,
+			300,
+		},
+		{
+			"Standby with standby timeouts",
+			&base.Config{ActiveTimeout: 10, IdleTimeout: 300, StandbyActiveTimeout: 60, StandbyIdleTimeout: 600},
+			true,
+			60,
+			600,
+		},
+		{
+			"Standby without standby timeouts",
+			&base.Config{ActiveTimeout: 10, IdleTimeout: 300},
+			true,
+			10,
+			300,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator := &Terminator{config: tc.config, inRecovery: tc.inRecovery}
+			active, idle := terminator.timeouts()
+			if active != tc.wantActive || idle != tc.wantIdle {
+				t.Errorf("got %f, %f; want %f, %f", active, idle, tc.wantActive, tc.wantIdle)
+			} else {
+				t.Logf("got %f, %f; want %f, %f", active, idle, tc.wantActive, tc.wantIdle)
+			}
+		})
+	}
+}
+
+func TestOldestSessions(t *testing.T) {
+	sessions := []*base.Session{
+		{User: "test", StateDuration: 10},
+		{User: "test_1", StateDuration: 300},
+		{User: "test_2", StateDuration: 60},
+	}
+	want := []string{"test_1", "test_2", "test"}
+	got := ListUsers(oldestSessions(sessions))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	} else {
+		t.Logf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestStandbyProtected(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    bool
+	}{
+		{"Query of a client is cancelled", &base.Session{Pid: 1, State: "active", ApplicationName: "psql"}, true},
+		{"Query of a backup is protected", &base.Session{Pid: 1, State: "active", ApplicationName: "pg_dump"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terminator, fake := newFakeTerminator(t, &base.Config{StandbyReplayLag: 1},
+				fakeResponse{match: "pg_last_xact_replay_timestamp", rows: [][]driver.Value{{float64(100)}}},
+				fakeResponse{match: "pg_cancel_backend"},
+			)
+			terminator.inRecovery = true
+			terminator.standby([]*base.Session{tc.session})
+			got := fake.signaled()
+			if got != tc.want {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
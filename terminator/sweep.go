@@ -0,0 +1,37 @@
+package terminator
+
+import (
+	"github.com/jouir/pgterminate/base"
+)
+
+// sweep terminates sessions of roles not allowed to login anymore and sessions connected to
+// databases not allowing connections anymore
+func (t *Terminator) sweep() {
+	if !t.config.SweepDisabledRoles && !t.config.SweepDisallowedDatabases {
+		return
+	}
+	targets := t.protect(t.filter(disallowedSessions(t.db.Disallowed(), t.config.SweepDisabledRoles, t.config.SweepDisallowedDatabases)))
+	t.db.TerminateSessions(targets)
+	t.notify(targets, base.SweepEvent)
+}
+
+// disallowedSessions returns sessions of disabled roles when roles is true and sessions of
+// databases not allowing connections when databases is true, with the cause as reason
+func disallowedSessions(disallowed []*base.Disallowed, roles bool, databases bool) (result []*base.Session) {
+	for _, d := range disallowed {
+		var reason string
+		switch {
+		case roles && !d.CanLogin:
+			reason = "rolcanlogin=false"
+		case roles && d.Expired:
+			reason = "rolvaliduntil=" + d.ValidUntil
+		case databases && !d.AllowConnections:
+			reason = "datallowconn=false"
+		default:
+			continue
+		}
+		d.Session.Reason = reason
+		result = append(result, d.Session)
+	}
+	return result
+}
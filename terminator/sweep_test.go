@@ -0,0 +1,41 @@
+package terminator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestDisallowedSessions(t *testing.T) {
+	tests := []struct {
+		name      string
+		roles     bool
+		databases bool
+		want      []string
+	}{
+		{"Roles", true, false, []string{"rolcanlogin=false", "rolvaliduntil=2026-01-01 00:00:00+00", "rolcanlogin=false"}},
+		{"Databases", false, true, []string{"datallowconn=false", "datallowconn=false"}},
+		{"Roles and databases", true, true, []string{"rolcanlogin=false", "rolvaliduntil=2026-01-01 00:00:00+00", "datallowconn=false", "rolcanlogin=false"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			disallowed := []*base.Disallowed{
+				{Session: &base.Session{Pid: 1}, CanLogin: false, AllowConnections: true},
+				{Session: &base.Session{Pid: 2}, CanLogin: true, Expired: true, ValidUntil: "2026-01-01 00:00:00+00", AllowConnections: true},
+				{Session: &base.Session{Pid: 3}, CanLogin: true, AllowConnections: false},
+				{Session: &base.Session{Pid: 4}, CanLogin: false, AllowConnections: false},
+			}
+			var got []string
+			for _, session := range disallowedSessions(disallowed, tc.roles, tc.databases) {
+				got = append(got, session.Reason)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			} else {
+				t.Logf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
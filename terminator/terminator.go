@@ -2,6 +2,7 @@ package terminator
 
 import (
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jouir/pgterminate/base"
@@ -11,18 +12,64 @@ import (
 // Terminator looks for sessions, filters actives and idles, terminate them and notify sessions channel
 // It ends itself gracefully when done channel is triggered
 type Terminator struct {
-	config   *base.Config
-	db       *base.Db
-	sessions chan *base.Session
-	done     chan bool
+	config               *base.Config
+	db                   *base.Db
+	sessions             chan *base.Session
+	done                 chan bool
+	reportedSlots        map[string]bool
+	inRecovery           bool
+	advisoryLocksSeen    map[string]time.Time
+	protectedSessions    map[int64]bool
+	protectedCurrent     map[int64]bool
+	hungSessions         map[int64]bool
+	hungCurrent          map[int64]bool
+	roles                map[string]*base.Role
+	rolesRefreshed       time.Time
+	settings             base.Settings
+	settingsRefreshed    time.Time
+	policiesRefreshed    time.Time
+	queryIDsImported     time.Time
+	hbaChecked           time.Time
+	hbaLoaded            time.Time
+	hook                 *base.Hook
+	hookReloaded         bool
+	skippedSessions      map[int64]time.Time
+	procDir              string
+	processesChecked     bool
+	processesLocal       bool
+	ioUnreadable         bool
+	resourceUsages       map[int64]*resourceUsage
+	stats                *base.Stats
+	statsSaved           time.Time
+	queryRuns            map[int64]*queryRun
+	offenders            map[offenderKey]*offender
+	throttles            map[string]*base.Throttle
+	currentDatabase      string
+	databases            map[string]*base.Db
+	unreachableDatabases map[string]time.Time
+	mutex                sync.Mutex
 }
 
 // NewTerminator instanciates a Terminator
 func NewTerminator(ctx *base.Context) *Terminator {
 	return &Terminator{
-		config:   ctx.Config,
-		sessions: ctx.Sessions,
-		done:     ctx.Done,
+		config:               ctx.Config,
+		sessions:             ctx.Sessions,
+		done:                 ctx.Done,
+		reportedSlots:        make(map[string]bool),
+		advisoryLocksSeen:    make(map[string]time.Time),
+		protectedSessions:    make(map[int64]bool),
+		protectedCurrent:     make(map[int64]bool),
+		hungSessions:         make(map[int64]bool),
+		hungCurrent:          make(map[int64]bool),
+		skippedSessions:      make(map[int64]time.Time),
+		procDir:              "/proc",
+		resourceUsages:       make(map[int64]*resourceUsage),
+		queryRuns:            make(map[int64]*queryRun),
+		offenders:            make(map[offenderKey]*offender),
+		throttles:            make(map[string]*base.Throttle),
+		databases:            make(map[string]*base.Db),
+		unreachableDatabases: make(map[string]time.Time),
 	}
 }
 
@@ -32,6 +79,8 @@ func (t *Terminator) Run() {
 	t.db = base.NewDb(t.config.Dsn())
 	log.Info("Connecting to instance")
 	t.db.Connect()
+	t.loadStats()
+	t.loadThrottles()
 	defer t.terminate()
 
 	for {
@@ -40,24 +89,64 @@ func (t *Terminator) Run() {
 			return
 		default:
 			sessions := t.db.Sessions()
+			t.detectRecovery()
+			t.refreshRoles()
+			t.refreshSettings()
+			t.refreshPolicies()
+			t.importQueryIDs()
+			t.label(sessions)
+			t.assignPolicies(sessions)
+			t.annotate(sessions)
+			t.forgetSkipped(sessions)
+			t.readResources(sessions, time.Now())
 
 			// Cancel or terminate active sessions
-			if t.config.ActiveTimeout != 0 {
-				actives := t.filter(activeSessions(sessions, t.config.ActiveTimeout))
-				if t.config.Cancel {
-					t.db.CancelSessions(actives)
-				} else {
-					t.db.TerminateSessions(actives)
-				}
-				t.notify(actives)
-			}
+			actives := t.decide(labelSessions(t.protect(t.filter(activeSessions(sessions, t.activeTimeout)))), base.ActiveEvent)
+			t.kill(actives)
+			t.notify(actives, base.ActiveEvent)
 
 			// Terminate idle sessions
-			if t.config.IdleTimeout != 0 {
-				idles := t.filter(idleSessions(sessions, t.config.IdleTimeout))
-				t.db.TerminateSessions(idles)
-				t.notify(idles)
-			}
+			idles := t.decide(labelSessions(t.protect(t.filter(idleSessions(sessions, t.idleTimeout)))), base.IdleEvent)
+			t.db.TerminateSessions(idles)
+			t.notify(idles, base.IdleEvent)
+
+			// Terminate lagging walsenders and report slots retaining WAL
+			t.replication()
+
+			// Cancel queries holding replay back on standbys
+			t.standby(sessions)
+
+			// Cancel DDL or terminate their blockers when a lock queue builds up
+			t.lockQueues(sessions)
+
+			// Cancel or terminate sessions locking relations
+			t.relations(sessions)
+
+			// Terminate sessions holding advisory locks for too long
+			t.advisory(sessions)
+
+			// Cancel autovacuum workers according to the autovacuum policy
+			t.autovacuum()
+
+			// Terminate sessions connected without the required encryption
+			t.security()
+
+			// Terminate sessions of disabled roles and databases not allowing connections
+			t.sweep()
+
+			// Report or terminate sessions rejected by current pg_hba.conf rules
+			t.hba()
+
+			// Cancel active queries using too many operating system resources
+			t.resources(sessions)
+
+			// Learn durations of queries and cancel queries running longer than usual
+			t.adaptive(sessions)
+
+			// Report clients killed too many times and throttle them
+			t.repeatOffenders()
+
+			t.rotateProtected()
 
 			time.Sleep(time.Duration(t.config.Interval*1000) * time.Millisecond)
 		}
@@ -65,11 +154,66 @@ func (t *Terminator) Run() {
 	}
 }
 
-// notify sends sessions to channel
-func (t *Terminator) notify(sessions []*base.Session) {
+// Reload forces roles, settings, policies and query identifiers of policies to be refreshed,
+// sessions to be checked against pg_hba.conf rules and the policy hook to be restarted on
+// next iteration
+// Executed when receiving SIGHUP signal
+func (t *Terminator) Reload() {
+	log.Info("Reloading terminator")
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.rolesRefreshed = time.Time{}
+	t.settingsRefreshed = time.Time{}
+	t.policiesRefreshed = time.Time{}
+	t.queryIDsImported = time.Time{}
+	t.hbaChecked = time.Time{}
+	t.hbaLoaded = time.Time{}
+	t.hookReloaded = true
+}
+
+// notify sends copies of sessions to channel, so rules changing sessions later in the
+// iteration don't race with notifiers, and counts kills of repeat offenders
+func (t *Terminator) notify(sessions []*base.Session, event string) {
+	for _, session := range sessions {
+		notified := *session
+		notified.Event = event
+		t.sessions <- &notified
+	}
+	if t.config.RepeatOffenderKills != 0 && t.killEvent(event) {
+		t.recordKills(sessions, time.Now())
+	}
+}
+
+// labelSessions sets the policy and the annotation as reason of sessions
+func labelSessions(sessions []*base.Session) []*base.Session {
 	for _, session := range sessions {
-		t.sessions <- session
+		var labels []string
+		if session.Policy != nil {
+			labels = append(labels, "policy="+session.Policy.Name)
+		}
+		if session.Annotation != nil {
+			labels = append(labels, "annotation="+session.Annotation.Text)
+		}
+		if labels != nil && session.Reason == "" {
+			session.Reason = strings.Join(labels, " ")
+		}
 	}
+	return sessions
+}
+
+// kill cancels or terminates sessions depending on their policy or the cancel option
+// Idle sessions are always terminated because cancelling them has no effect
+func (t *Terminator) kill(sessions []*base.Session) {
+	var cancels, terminates []*base.Session
+	for _, session := range sessions {
+		if t.cancel(session) && !session.IsIdle() {
+			cancels = append(cancels, session)
+		} else {
+			terminates = append(terminates, session)
+		}
+	}
+	t.db.CancelSessions(cancels)
+	t.db.TerminateSessions(terminates)
 }
 
 // filterListeners excludes sessions with last query starting with "LISTEN"
@@ -150,26 +294,46 @@ func (t *Terminator) filterDatabases(sessions []*base.Session) []*base.Session {
 	return filtered
 }
 
+// filterMatch includes sessions matching the match expression
+func (t *Terminator) filterMatch(sessions []*base.Session) (filtered []*base.Session) {
+	if t.config.MatchCompiled == nil {
+		return sessions
+	}
+	for _, session := range sessions {
+		if t.config.MatchCompiled.Eval(session) {
+			filtered = append(filtered, session)
+		}
+	}
+	return filtered
+}
+
 // filter executes all filter functions on a list of sessions
 func (t *Terminator) filter(sessions []*base.Session) (filtered []*base.Session) {
+	t.label(sessions)
 	filtered = t.filterListeners(sessions)
 	filtered = t.filterUsers(filtered)
+	filtered = t.filterRoles(filtered)
 	filtered = t.filterDatabases(filtered)
+	filtered = t.filterMatch(filtered)
 	return filtered
 }
 
 // terminate terminates gracefully
 func (t *Terminator) terminate() {
+	t.terminateHook()
+	t.saveStats()
+	t.restoreRoles(time.Now(), true)
+	t.closeDatabases()
 	log.Info("Disconnecting from instance")
 	t.db.Disconnect()
 }
 
 // activeSessions returns a list of active sessions
-// A session is active when state is "active" and state has changed before elapsed seconds
-// seconds
-func activeSessions(sessions []*base.Session, elapsed float64) (result []*base.Session) {
+// A session is active when state is "active" and state has changed before its timeout
+// in seconds. A zero timeout disables the rule for the session.
+func activeSessions(sessions []*base.Session, timeout func(*base.Session) float64) (result []*base.Session) {
 	for _, session := range sessions {
-		if session.State == "active" && session.StateDuration > elapsed {
+		if elapsed := timeout(session); elapsed != 0 && session.State == "active" && session.StateDuration > elapsed {
 			result = append(result, session)
 		}
 	}
@@ -178,10 +342,11 @@ func activeSessions(sessions []*base.Session, elapsed float64) (result []*base.S
 
 // idleSessions returns a list of idle sessions
 // A sessions is idle when state is "idle",  "idle in transaction" or "idle in transaction
-// (aborted)"and state has changed before elapsed seconds
-func idleSessions(sessions []*base.Session, elapsed float64) (result []*base.Session) {
+// (aborted)"and state has changed before its timeout in seconds. A zero timeout disables the
+// rule for the session.
+func idleSessions(sessions []*base.Session, timeout func(*base.Session) float64) (result []*base.Session) {
 	for _, session := range sessions {
-		if session.IsIdle() && session.StateDuration > elapsed {
+		if elapsed := timeout(session); elapsed != 0 && session.IsIdle() && session.StateDuration > elapsed {
 			result = append(result, session)
 		}
 	}
@@ -182,3 +182,62 @@ func ListDatabases(sessions []*base.Session) (databases []string) {
 	}
 	return databases
 }
+
+func TestFilterMatch(t *testing.T) {
+
+	sessions := []*base.Session{
+		{User: "test", Client: "10.0.0.1:5432"},
+		{User: "test_1", Client: "192.168.0.1:5432"},
+		{User: "postgres", Client: "localhost"},
+	}
+
+	tests := []struct {
+		name   string
+		config *base.Config
+		want   []*base.Session
+	}{
+		{
+			"No expression",
+			&base.Config{},
+			sessions,
+		},
+		{
+			"Match users",
+			&base.Config{MatchExpression: "user ~ '^test'"},
+			[]*base.Session{sessions[0], sessions[1]},
+		},
+		{
+			"Match clients",
+			&base.Config{MatchExpression: "client in '10.0.0.0/8' or client = 'localhost'"},
+			[]*base.Session{sessions[0], sessions[2]},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.CompileRegexes()
+			if err != nil {
+				t.Errorf("Failed to compile expression: %v", err)
+			}
+			terminator := &Terminator{config: tc.config}
+			got := terminator.filterMatch(sessions)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", ListUsers(got), ListUsers(tc.want))
+			} else {
+				t.Logf("got %+v; want %+v", ListUsers(got), ListUsers(tc.want))
+			}
+		})
+	}
+}
+
+func TestNotifyCopies(t *testing.T) {
+	terminator := &Terminator{config: &base.Config{}, sessions: make(chan *base.Session, 1)}
+	session := &base.Session{Pid: 42, Reason: "first"}
+	terminator.notify([]*base.Session{session}, base.ActiveEvent)
+	session.Reason = "second"
+
+	got := <-terminator.sessions
+	if got == session || got.Event != base.ActiveEvent || got.Reason != "first" || session.Event != "" {
+		t.Errorf("got %+v; want a copy with event %s and reason first", got, base.ActiveEvent)
+	}
+}
@@ -0,0 +1,20 @@
+package terminator
+
+import (
+	"github.com/jouir/pgterminate/base"
+)
+
+// transactionTimeout returns the timeout of sessions idle in a write or read-only
+// transaction when configured or the default timeout
+func (t *Terminator) transactionTimeout(session *base.Session, timeout float64) float64 {
+	if !session.IsIdleInTransaction() {
+		return timeout
+	}
+	switch {
+	case session.Transaction == base.WriteTransaction && t.config.IdleInWriteTransactionTimeout != 0:
+		return t.config.IdleInWriteTransactionTimeout
+	case session.Transaction == base.ReadOnlyTransaction && t.config.IdleInReadOnlyTransactionTimeout != 0:
+		return t.config.IdleInReadOnlyTransactionTimeout
+	}
+	return timeout
+}
@@ -0,0 +1,35 @@
+package terminator
+
+import (
+	"testing"
+
+	"github.com/jouir/pgterminate/base"
+)
+
+func TestTransactionTimeout(t *testing.T) {
+	config := &base.Config{IdleTimeout: 300, IdleInWriteTransactionTimeout: 60, IdleInReadOnlyTransactionTimeout: 600}
+	terminator := &Terminator{config: config}
+
+	tests := []struct {
+		name    string
+		session *base.Session
+		want    float64
+	}{
+		{"Idle", &base.Session{State: "idle"}, 300},
+		{"Write transaction", &base.Session{State: "idle in transaction", Transaction: base.WriteTransaction}, 60},
+		{"Aborted write transaction", &base.Session{State: "idle in transaction (aborted)", Transaction: base.WriteTransaction}, 60},
+		{"Read-only transaction", &base.Session{State: "idle in transaction", Transaction: base.ReadOnlyTransaction}, 600},
+		{"Active write transaction", &base.Session{State: "active", Transaction: base.WriteTransaction}, 300},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := terminator.idleTimeout(tc.session)
+			if got != tc.want {
+				t.Errorf("got %f; want %f", got, tc.want)
+			} else {
+				t.Logf("got %f; want %f", got, tc.want)
+			}
+		})
+	}
+}